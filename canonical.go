@@ -0,0 +1,254 @@
+package bencode
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// maxParseDepth limits the nesting of the values that are read recursively
+// from a whole document: by Canonicalize and IsCanonical, and into a Value.
+const maxParseDepth = 1 << 16
+
+// Canonicalize appends the canonical form of the bencode value in src to dst
+// and returns the extended buffer: integers without leading zeros or a
+// negative zero, string lengths without leading zeros, and dictionary keys
+// in sorted order. When a key appears more than once the last value wins, as
+// it does when decoding. src must hold exactly one value that the Decoder
+// can parse, with integers that fit in an int64 and containers nested at
+// most 65536 deep; otherwise dst is returned unchanged along with an error.
+func Canonicalize(dst, src []byte) ([]byte, error) {
+	if err := checkValid(src); err != nil {
+		return dst, err
+	}
+	v, _, err := parseCanonical(src, 0)
+	if err != nil {
+		return dst, err
+	}
+	return appendCanonical(dst, v), nil
+}
+
+// canonValue is a bencode value read by parseCanonical: an integer or a
+// string held as its text, or a list or dictionary held as its elements.
+type canonValue struct {
+	kind  byte // 'i', 's', 'l' or 'd'
+	text  []byte
+	keys  [][]byte // the key of each element of a dictionary
+	elems []canonValue
+}
+
+// parseCanonical parses the valid bencode value at the start of src, inside
+// depth containers, and returns the remainder of src. Each byte is read
+// once, so that the spans of the entries of a dictionary are known before
+// they are sorted without scanning them again.
+func parseCanonical(src []byte, depth int) (canonValue, []byte, error) {
+	switch src[0] {
+	case 'i':
+		end := bytes.IndexByte(src, 'e')
+		if err := checkInt(src[1:end]); err != nil {
+			return canonValue{}, nil, err
+		}
+		return canonValue{kind: 'i', text: src[1:end]}, src[end+1:], nil
+
+	case 'l', 'd':
+		if err := checkDepth(depth + 1); err != nil {
+			return canonValue{}, nil, err
+		}
+		v := canonValue{kind: src[0]}
+		src = src[1:]
+		for src[0] != 'e' {
+			if v.kind == 'd' {
+				var key []byte
+				key, src = splitString(src)
+				if err := checkString(key); err != nil {
+					return canonValue{}, nil, err
+				}
+				v.keys = append(v.keys, key)
+			}
+			elem, rest, err := parseCanonical(src, depth+1)
+			if err != nil {
+				return canonValue{}, nil, err
+			}
+			v.elems = append(v.elems, elem)
+			src = rest
+		}
+		return v, src[1:], nil
+	}
+
+	str, rest := splitString(src)
+	if err := checkString(str); err != nil {
+		return canonValue{}, nil, err
+	}
+	return canonValue{kind: 's', text: str}, rest, nil
+}
+
+// checkInt returns an error if the integer digits, which may have a leading
+// minus sign, do not fit in an int64, as the Decoder does.
+func checkInt(digits []byte) error {
+	_, err := strconv.ParseInt(string(digits), 10, 64)
+	return err
+}
+
+// checkString returns an error if str is longer than the strings the
+// Decoder reads into memory.
+func checkString(str []byte) error {
+	if int64(len(str)) > math.MaxInt32 {
+		return fmt.Errorf("string length %d exceeds the limit of %d", len(str), math.MaxInt32)
+	}
+	return nil
+}
+
+// checkDepth returns an error if containers are nested depth deep, more than
+// maxParseDepth allows.
+func checkDepth(depth int) error {
+	if depth > maxParseDepth {
+		return fmt.Errorf("nesting depth exceeds the limit of %d", maxParseDepth)
+	}
+	return nil
+}
+
+// appendCanonical appends the canonical form of v to dst.
+func appendCanonical(dst []byte, v canonValue) []byte {
+	switch v.kind {
+	case 'i':
+		dst = append(dst, 'i')
+		dst = appendCanonicalInt(dst, v.text)
+		return append(dst, 'e')
+
+	case 'l':
+		dst = append(dst, 'l')
+		for _, elem := range v.elems {
+			dst = appendCanonical(dst, elem)
+		}
+		return append(dst, 'e')
+
+	case 'd':
+		order := make([]int, len(v.elems))
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(i, j int) bool {
+			return bytes.Compare(v.keys[order[i]], v.keys[order[j]]) < 0
+		})
+
+		dst = append(dst, 'd')
+		for i, n := range order {
+			// keep only the last of a run of equal keys
+			if i+1 < len(order) && bytes.Equal(v.keys[n], v.keys[order[i+1]]) {
+				continue
+			}
+			dst = appendString(dst, v.keys[n])
+			dst = appendCanonical(dst, v.elems[n])
+		}
+		return append(dst, 'e')
+	}
+
+	return appendString(dst, v.text)
+}
+
+// appendCanonicalInt appends the canonical spelling of the integer digits,
+// which may have a leading minus sign, to dst.
+func appendCanonicalInt(dst, digits []byte) []byte {
+	neg := digits[0] == '-'
+	if neg {
+		digits = digits[1:]
+	}
+	for len(digits) > 1 && digits[0] == '0' {
+		digits = digits[1:]
+	}
+	if neg && !(len(digits) == 1 && digits[0] == '0') {
+		dst = append(dst, '-')
+	}
+	return append(dst, digits...)
+}
+
+// appendString appends str encoded as a bencode string to dst.
+func appendString(dst, str []byte) []byte {
+	dst = strconv.AppendInt(dst, int64(len(str)), 10)
+	dst = append(dst, ':')
+	return append(dst, str...)
+}
+
+// splitString splits the valid bencode string at the start of src into its
+// contents and the remainder of src.
+func splitString(src []byte) (str, rest []byte) {
+	colon := bytes.IndexByte(src, ':')
+	n, _ := strconv.ParseInt(string(src[:colon]), 10, 64)
+	rest = src[colon+1:]
+	return rest[:n], rest[n:]
+}
+
+// IsCanonical reports whether data holds exactly one bencode value in
+// canonical form, so that Canonicalize would return it unchanged. A value
+// that Canonicalize rejects is not canonical.
+func IsCanonical(data []byte) bool {
+	if checkValid(data) != nil {
+		return false
+	}
+	_, ok := isCanonical(data, 0)
+	return ok
+}
+
+// isCanonical reports whether the valid bencode value at the start of src,
+// inside depth containers, is canonical, and returns the remainder of src.
+func isCanonical(src []byte, depth int) ([]byte, bool) {
+	switch src[0] {
+	case 'i':
+		end := bytes.IndexByte(src, 'e')
+		digits := src[1:end]
+		if checkInt(digits) != nil {
+			return nil, false
+		}
+		if digits[0] == '-' {
+			digits = digits[1:]
+			if digits[0] == '0' {
+				return nil, false
+			}
+		}
+		return src[end+1:], digits[0] != '0' || len(digits) == 1
+
+	case 'l':
+		if checkDepth(depth+1) != nil {
+			return nil, false
+		}
+		src = src[1:]
+		for src[0] != 'e' {
+			var ok bool
+			if src, ok = isCanonical(src, depth+1); !ok {
+				return nil, false
+			}
+		}
+		return src[1:], true
+
+	case 'd':
+		if checkDepth(depth+1) != nil {
+			return nil, false
+		}
+		var last []byte
+		src = src[1:]
+		for first := true; src[0] != 'e'; first = false {
+			key, rest, ok := canonicalString(src)
+			if !ok || (!first && bytes.Compare(last, key) >= 0) {
+				return nil, false
+			}
+			if src, ok = isCanonical(rest, depth+1); !ok {
+				return nil, false
+			}
+			last = key
+		}
+		return src[1:], true
+	}
+
+	_, rest, ok := canonicalString(src)
+	return rest, ok
+}
+
+// canonicalString splits the valid bencode string at the start of src as
+// splitString does, and also reports whether its length is canonical and
+// accepted by the Decoder.
+func canonicalString(src []byte) (str, rest []byte, ok bool) {
+	str, rest = splitString(src)
+	return str, rest, (src[0] != '0' || src[1] == ':') && checkString(str) == nil
+}
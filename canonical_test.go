@@ -0,0 +1,132 @@
+package bencode
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCanonicalize(t *testing.T) {
+	type testCase struct {
+		in  string
+		out string
+		err bool
+	}
+
+	var cases = []testCase{
+		{`i5e`, `i5e`, false},
+		{`i007e`, `i7e`, false},
+		{`i-007e`, `i-7e`, false},
+		{`i-0e`, `i0e`, false},
+		{`i000e`, `i0e`, false},
+		{`03:foo`, `3:foo`, false},
+		{`00:`, `0:`, false},
+		{`li01ei2ee`, `li1ei2ee`, false},
+		{`d1:bi1e1:ai2ee`, `d1:ai2e1:bi1ee`, false},
+		{`d1:ai1e1:ai2ee`, `d1:ai2ee`, false},
+		{`d1:bd1:di0e1:ci00ee1:alee`, `d1:ale1:bd1:ci0e1:di0eee`, false},
+		{`d2:ab0:1:a0:e`, `d1:a0:2:ab0:e`, false},
+		{`i-09223372036854775808e`, `i-9223372036854775808e`, false},
+
+		{`i1ei2e`, ``, true},
+		{`d1:a`, ``, true},
+		{`i99999999999999999999999e`, ``, true},
+		{`d1:ali9223372036854775808eee`, ``, true},
+	}
+
+	for i, tt := range cases {
+		out, err := Canonicalize(nil, []byte(tt.in))
+		if !tt.err && err != nil {
+			t.Errorf("#%d (%q): Unexpected err: %v", i, tt.in, err)
+			continue
+		}
+		if tt.err && err == nil {
+			t.Errorf("#%d (%q): Expected err is nil", i, tt.in)
+			continue
+		}
+		if string(out) != tt.out {
+			t.Errorf("#%d: Val: %q != %q", i, out, tt.out)
+		}
+	}
+}
+
+func TestCanonicalizeDeep(t *testing.T) {
+	// every dictionary is read once however deep it is nested
+	const n = 20000
+	in := strings.Repeat("d1:bi0e1:a", n) + "0:" + strings.Repeat("e", n)
+	expect := strings.Repeat("d1:a", n) + "0:" + strings.Repeat("1:bi0ee", n)
+
+	out, err := Canonicalize(nil, []byte(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != expect {
+		t.Errorf("Val: wrong canonical form of %d nested dictionaries", n)
+	}
+	if !IsCanonical(out) {
+		t.Errorf("IsCanonical = false")
+	}
+}
+
+func TestCanonicalizeDepth(t *testing.T) {
+	nested := func(n int) []byte {
+		return []byte(strings.Repeat("l", n) + strings.Repeat("e", n))
+	}
+
+	if _, err := Canonicalize(nil, nested(maxParseDepth)); err != nil {
+		t.Errorf("Unexpected err: %v", err)
+	}
+	if !IsCanonical(nested(maxParseDepth)) {
+		t.Errorf("IsCanonical = false")
+	}
+	if _, err := Canonicalize(nil, nested(maxParseDepth+1)); err == nil {
+		t.Errorf("Expected err is nil")
+	}
+	if IsCanonical(nested(maxParseDepth + 1)) {
+		t.Errorf("IsCanonical = true")
+	}
+}
+
+func TestIsCanonical(t *testing.T) {
+	type testCase struct {
+		in        string
+		canonical bool
+	}
+
+	var cases = []testCase{
+		{`i0e`, true},
+		{`i-1e`, true},
+		{`i10e`, true},
+		{`0:`, true},
+		{`10:0123456789`, true},
+		{`li1ee`, true},
+		{`d1:ai1e1:bi2ee`, true},
+		{`d1:ad1:xlee1:b0:e`, true},
+		{`d1:a0:2:ab0:e`, true},
+		{`i9223372036854775807e`, true},
+		{`i-9223372036854775808e`, true},
+
+		{`i01e`, false},
+		{`i-0e`, false},
+		{`i-01e`, false},
+		{`03:foo`, false},
+		{`00:`, false},
+		{`li01ee`, false},
+		{`d1:bi1e1:ai2ee`, false},
+		{`d1:ai1e1:ai2ee`, false},
+		{`d1:ad1:yi0e1:xi0eee`, false},
+		{`d01:ai1ee`, false},
+		{`i1`, false},
+		{`i1ei2e`, false},
+		{`i99999999999999999999999e`, false},
+		{`li9223372036854775808ee`, false},
+	}
+
+	for i, tt := range cases {
+		if got := IsCanonical([]byte(tt.in)); got != tt.canonical {
+			t.Errorf("#%d (%q): IsCanonical = %v", i, tt.in, got)
+		}
+		if out, err := Canonicalize(nil, []byte(tt.in)); err == nil && (string(out) == tt.in) != tt.canonical {
+			t.Errorf("#%d (%q): Canonicalize disagrees: %q", i, tt.in, out)
+		}
+	}
+}
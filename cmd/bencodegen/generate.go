@@ -0,0 +1,734 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const bencodePath = "github.com/zeebo/bencode"
+
+// kind is the kind of a field type the generator supports.
+type kind int
+
+const (
+	kindString kind = iota
+	kindBytes
+	kindBool
+	kindInt
+	kindUint
+	kindStruct
+	kindPointer
+	kindSlice
+	kindMap
+)
+
+// fieldType is a supported type, with the Go source of its name.
+type fieldType struct {
+	kind kind
+	name string
+	elem *fieldType // the element of a pointer, slice or map
+}
+
+// field is a dictionary entry of a struct type.
+type field struct {
+	key       string
+	path      string // the selector of the field from the struct value
+	typ       *fieldType
+	omitempty bool
+}
+
+// these methods change how the bencode package encodes a type, so that
+// generated code would not match it.
+var encodingMethods = map[string]bool{
+	"MarshalBencode":       true,
+	"MarshalBencodeTo":     true,
+	"MarshalText":          true,
+	"UnmarshalBencode":     true,
+	"UnmarshalBencodeFrom": true,
+	"UnmarshalText":        true,
+}
+
+type generator struct {
+	pkg     string
+	decls   map[string]ast.Expr // the type declarations of the package
+	methods map[string][]string // the encoding methods of each type
+	types   map[string]bool     // the types to generate methods for
+	imports map[string]bool
+	buf     bytes.Buffer
+	tests   bytes.Buffer
+}
+
+// generate returns the formatted source of the methods for the named struct
+// types in the package in dir, and of a test of those methods.
+func generate(dir string, types []string) (src, test []byte, err error) {
+	g := &generator{
+		decls:   make(map[string]ast.Expr),
+		methods: make(map[string][]string),
+		types:   make(map[string]bool),
+		imports: make(map[string]bool),
+	}
+	if err := g.parse(dir); err != nil {
+		return nil, nil, err
+	}
+	for _, name := range types {
+		if _, ok := g.decls[name].(*ast.StructType); !ok {
+			return nil, nil, fmt.Errorf("%s is not a struct type in package %s", name, g.pkg)
+		}
+		g.types[name] = true
+	}
+
+	for _, name := range types {
+		fields, err := g.fields(name, g.decls[name].(*ast.StructType), "")
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := checkKeys(name, fields); err != nil {
+			return nil, nil, err
+		}
+		g.genMarshal(name, fields)
+		g.genUnmarshal(name, fields)
+		g.genTest(name, fields)
+	}
+
+	header := fmt.Sprintf("// Code generated by bencodegen -type %s; DO NOT EDIT.\n\n", strings.Join(types, ","))
+
+	var out bytes.Buffer
+	out.WriteString(header)
+	fmt.Fprintf(&out, "package %s\n\n", g.pkg)
+	fmt.Fprintf(&out, "import (\n")
+	var imports []string
+	for path := range g.imports {
+		if path != bencodePath {
+			imports = append(imports, path)
+		}
+	}
+	sort.Strings(imports)
+	for _, path := range imports {
+		fmt.Fprintf(&out, "%q\n", path)
+	}
+	fmt.Fprintf(&out, "\n%q\n", bencodePath)
+	fmt.Fprintf(&out, ")\n")
+	out.Write(g.buf.Bytes())
+
+	var tests bytes.Buffer
+	tests.WriteString(header)
+	fmt.Fprintf(&tests, "package %s\n\n", g.pkg)
+	fmt.Fprintf(&tests, "import (\n\"bytes\"\n\"reflect\"\n\"testing\"\n\n%q\n)\n", bencodePath)
+	tests.Write(g.tests.Bytes())
+
+	if src, err = formatSource(out.Bytes()); err != nil {
+		return nil, nil, err
+	}
+	if test, err = formatSource(tests.Bytes()); err != nil {
+		return nil, nil, err
+	}
+	return src, test, nil
+}
+
+// formatSource formats the generated source b.
+func formatSource(b []byte) ([]byte, error) {
+	src, err := format.Source(b)
+	if err != nil {
+		return nil, fmt.Errorf("internal error: invalid generated code: %v", err)
+	}
+	return src, nil
+}
+
+// parse reads the type declarations and methods of the package in dir,
+// leaving out its tests.
+func (g *generator) parse(dir string) error {
+	names, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return err
+	}
+
+	fset := token.NewFileSet()
+	for _, name := range names {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, name, nil, parser.SkipObjectResolution)
+		if err != nil {
+			return err
+		}
+		if g.pkg == "" {
+			g.pkg = f.Name.Name
+		} else if g.pkg != f.Name.Name {
+			return fmt.Errorf("found packages %s and %s in %s", g.pkg, f.Name.Name, dir)
+		}
+
+		for _, decl := range f.Decls {
+			switch decl := decl.(type) {
+			case *ast.GenDecl:
+				for _, spec := range decl.Specs {
+					if ts, ok := spec.(*ast.TypeSpec); ok && ts.TypeParams == nil {
+						g.decls[ts.Name.Name] = ts.Type
+					}
+				}
+			case *ast.FuncDecl:
+				if decl.Recv == nil || !encodingMethods[decl.Name.Name] {
+					continue
+				}
+				recv := decl.Recv.List[0].Type
+				if star, ok := recv.(*ast.StarExpr); ok {
+					recv = star.X
+				}
+				if id, ok := recv.(*ast.Ident); ok {
+					g.methods[id.Name] = append(g.methods[id.Name], decl.Name.Name)
+				}
+			}
+		}
+	}
+	if g.pkg == "" {
+		return fmt.Errorf("no Go files in %s", dir)
+	}
+	return nil
+}
+
+// fields returns the dictionary entries of the struct type st as the bencode
+// package reads them, with the selectors of embedded fields after prefix.
+func (g *generator) fields(name string, st *ast.StructType, prefix string) ([]field, error) {
+	var fields []field
+	for _, f := range st.Fields.List {
+		var tag reflect.StructTag
+		if f.Tag != nil {
+			s, err := strconv.Unquote(f.Tag.Value)
+			if err != nil {
+				return nil, err
+			}
+			tag = reflect.StructTag(s)
+		}
+
+		// untagged embedded structs are flattened into the parent
+		if len(f.Names) == 0 {
+			id, ok := f.Type.(*ast.Ident)
+			if !ok {
+				return nil, fmt.Errorf("%s: unsupported embedded field %s", name, exprString(f.Type))
+			}
+			if !ast.IsExported(id.Name) {
+				continue
+			}
+			embedded, ok := g.decls[id.Name].(*ast.StructType)
+			if !ok || f.Tag != nil {
+				return nil, fmt.Errorf("%s: unsupported embedded field %s", name, id.Name)
+			}
+			if err := g.checkMethods(id.Name); err != nil {
+				return nil, err
+			}
+			sub, err := g.fields(name, embedded, prefix+id.Name+".")
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, sub...)
+			continue
+		}
+
+		for _, id := range f.Names {
+			if !ast.IsExported(id.Name) {
+				continue
+			}
+			tagValue := tag.Get("bencode")
+			if tagValue == "-" {
+				continue
+			}
+
+			key, opts := id.Name, ""
+			if i := strings.Index(tagValue, ","); i >= 0 {
+				tagValue, opts = tagValue[:i], tagValue[i+1:]
+			}
+			if tagValue != "" {
+				if !isValidTag(tagValue) {
+					return nil, fmt.Errorf("%s.%s: invalid key %q", name, id.Name, tagValue)
+				}
+				key = tagValue
+			}
+
+			omitempty := false
+			for _, opt := range strings.Split(opts, ",") {
+				switch opt {
+				case "":
+				case "omitempty":
+					omitempty = true
+				default:
+					return nil, fmt.Errorf("%s.%s: unsupported option %q", name, id.Name, opt)
+				}
+			}
+
+			typ, err := g.resolve(f.Type)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %v", name, id.Name, err)
+			}
+			fields = append(fields, field{key, prefix + id.Name, typ, omitempty})
+		}
+	}
+	return fields, nil
+}
+
+// checkKeys sorts fields by key and checks that no key is repeated, as the
+// bencode package would write both entries but decode only one.
+func checkKeys(name string, fields []field) error {
+	sort.SliceStable(fields, func(i, j int) bool { return fields[i].key < fields[j].key })
+	for i := 1; i < len(fields); i++ {
+		if fields[i].key == fields[i-1].key {
+			return fmt.Errorf("%s: fields %s and %s have the same key %q",
+				name, fields[i-1].path, fields[i].path, fields[i].key)
+		}
+	}
+	return nil
+}
+
+// checkMethods returns an error if the type name has methods that change
+// how the bencode package encodes it.
+func (g *generator) checkMethods(name string) error {
+	if methods := g.methods[name]; len(methods) > 0 && !g.types[name] {
+		return fmt.Errorf("type %s has a %s method", name, methods[0])
+	}
+	return nil
+}
+
+// resolve returns the supported type for expr.
+func (g *generator) resolve(expr ast.Expr) (*fieldType, error) {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		switch t.Name {
+		case "string":
+			return &fieldType{kind: kindString, name: t.Name}, nil
+		case "bool":
+			return &fieldType{kind: kindBool, name: t.Name}, nil
+		case "int", "int8", "int16", "int32", "int64":
+			return &fieldType{kind: kindInt, name: t.Name}, nil
+		case "uint", "uint8", "byte", "uint16", "uint32", "uint64":
+			return &fieldType{kind: kindUint, name: t.Name}, nil
+		}
+
+		decl, ok := g.decls[t.Name]
+		if !ok {
+			return nil, fmt.Errorf("unsupported type %s", t.Name)
+		}
+		if err := g.checkMethods(t.Name); err != nil {
+			return nil, err
+		}
+		switch decl.(type) {
+		case *ast.StructType:
+			if !g.types[t.Name] {
+				return nil, fmt.Errorf("struct type %s is not one of the -type types", t.Name)
+			}
+			return &fieldType{kind: kindStruct, name: t.Name}, nil
+		case *ast.Ident:
+			// a named type of a basic type
+			under, err := g.resolve(decl)
+			if err != nil || under.kind > kindUint {
+				return nil, fmt.Errorf("unsupported type %s", t.Name)
+			}
+			return &fieldType{kind: under.kind, name: t.Name}, nil
+		}
+		return nil, fmt.Errorf("unsupported type %s", t.Name)
+
+	case *ast.StarExpr:
+		elem, err := g.resolve(t.X)
+		if err != nil {
+			return nil, err
+		}
+		if elem.kind == kindPointer {
+			return nil, fmt.Errorf("unsupported type %s", exprString(t))
+		}
+		return &fieldType{kind: kindPointer, name: "*" + elem.name, elem: elem}, nil
+
+	case *ast.ArrayType:
+		if t.Len != nil {
+			return nil, fmt.Errorf("unsupported type %s", exprString(t))
+		}
+		if id, ok := t.Elt.(*ast.Ident); ok && (id.Name == "byte" || id.Name == "uint8") {
+			return &fieldType{kind: kindBytes, name: "[]byte"}, nil
+		}
+		elem, err := g.resolve(t.Elt)
+		if err != nil {
+			return nil, err
+		}
+		return &fieldType{kind: kindSlice, name: "[]" + elem.name, elem: elem}, nil
+
+	case *ast.MapType:
+		if id, ok := t.Key.(*ast.Ident); !ok || id.Name != "string" {
+			return nil, fmt.Errorf("unsupported type %s", exprString(t))
+		}
+		elem, err := g.resolve(t.Value)
+		if err != nil {
+			return nil, err
+		}
+		return &fieldType{kind: kindMap, name: "map[string]" + elem.name, elem: elem}, nil
+	}
+	return nil, fmt.Errorf("unsupported type %s", exprString(expr))
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// genMarshal writes the MarshalBencode and AppendBencode methods of name.
+func (g *generator) genMarshal(name string, fields []field) {
+	g.printf("\n// MarshalBencode returns the bencoding of v.\n")
+	g.printf("func (v %s) MarshalBencode() ([]byte, error) {\n", name)
+	g.printf("return v.AppendBencode(nil), nil\n")
+	g.printf("}\n")
+
+	g.printf("\n// AppendBencode appends the bencoding of v to b and returns the result.\n")
+	g.printf("func (v %s) AppendBencode(b []byte) []byte {\n", name)
+	g.printf("b = append(b, 'd')\n")
+	for _, f := range fields {
+		x := "v." + f.path
+		cond := ""
+		switch {
+		case f.typ.kind == kindPointer:
+			cond = x + " != nil"
+		case f.omitempty:
+			cond = nonEmpty(x, f.typ)
+		}
+		if cond != "" {
+			g.printf("if %s {\n", cond)
+		}
+		g.printf("b = append(b, %s...)\n", strconv.Quote(strconv.Itoa(len(f.key))+":"+f.key))
+		g.encode(x, f.typ, 0)
+		if cond != "" {
+			g.printf("}\n")
+		}
+	}
+	g.printf("return append(b, 'e')\n")
+	g.printf("}\n")
+}
+
+// genTest writes a test that compares the generated methods of name with
+// the encoding and decoding of the bencode package by reflection, for the
+// zero value and for a value with every field set.
+func (g *generator) genTest(name string, fields []field) {
+	fmt.Fprintf(&g.tests, "\nfunc Test%sBencode(t *testing.T) {\n", name)
+	fmt.Fprintf(&g.tests, "// plain has the fields of %s without its methods\n", name)
+	fmt.Fprintf(&g.tests, "type plain %s\n\n", name)
+	fmt.Fprintf(&g.tests, "var full %s\n", name)
+	for _, f := range fields {
+		fmt.Fprintf(&g.tests, "full.%s = %s\n", f.path, sampleValue(f.typ))
+	}
+	fmt.Fprintf(&g.tests, "\nfor i, v := range []%s{{}, full} {\n", name)
+	fmt.Fprintf(&g.tests, "got, err := v.MarshalBencode()\n")
+	fmt.Fprintf(&g.tests, "if err != nil {\nt.Fatalf(\"#%%d: Unexpected err: %%v\", i, err)\n}\n")
+	fmt.Fprintf(&g.tests, "expect, err := bencode.EncodeBytes(plain(v))\n")
+	fmt.Fprintf(&g.tests, "if err != nil {\nt.Fatalf(\"#%%d: Unexpected err: %%v\", i, err)\n}\n")
+	fmt.Fprintf(&g.tests, "if !bytes.Equal(got, expect) {\nt.Errorf(\"#%%d: Val: %%q != %%q\", i, got, expect)\n}\n\n")
+	fmt.Fprintf(&g.tests, "var back %s\n", name)
+	fmt.Fprintf(&g.tests, "if err := back.UnmarshalBencode(got); err != nil {\nt.Fatalf(\"#%%d: Unexpected err: %%v\", i, err)\n}\n")
+	fmt.Fprintf(&g.tests, "var plainBack plain\n")
+	fmt.Fprintf(&g.tests, "if err := bencode.DecodeBytes(got, &plainBack); err != nil {\nt.Fatalf(\"#%%d: Unexpected err: %%v\", i, err)\n}\n")
+	fmt.Fprintf(&g.tests, "if !reflect.DeepEqual(back, %s(plainBack)) {\nt.Errorf(\"#%%d: Val: %%#v != %%#v\", i, back, plainBack)\n}\n", name)
+	fmt.Fprintf(&g.tests, "}\n")
+	fmt.Fprintf(&g.tests, "}\n")
+}
+
+// sampleValue returns an expression of type t that is not empty. Structs
+// are left as their zero values, which their own tests fill in.
+func sampleValue(t *fieldType) string {
+	switch t.kind {
+	case kindString:
+		return `"a"`
+	case kindBytes:
+		return `[]byte("a")`
+	case kindBool:
+		return "true"
+	case kindInt:
+		return "-1"
+	case kindUint:
+		return "1"
+	case kindStruct:
+		return t.name + "{}"
+	case kindPointer:
+		return "new(" + t.elem.name + ")"
+	case kindSlice:
+		return t.name + "{" + sampleElem(t.elem) + "}"
+	case kindMap:
+		return t.name + `{"a": ` + sampleElem(t.elem) + "}"
+	}
+	return ""
+}
+
+// sampleElem returns sampleValue(t) as an element of a composite literal,
+// leaving out the type of a composite literal as gofmt -s does.
+func sampleElem(t *fieldType) string {
+	switch t.kind {
+	case kindStruct, kindSlice, kindMap:
+		return strings.TrimPrefix(sampleValue(t), t.name)
+	}
+	return sampleValue(t)
+}
+
+// nonEmpty returns the condition for x of type t to be written with the
+// omitempty option.
+func nonEmpty(x string, t *fieldType) string {
+	switch t.kind {
+	case kindString, kindBytes, kindSlice, kindMap:
+		return "len(" + x + ") != 0"
+	case kindBool:
+		return x
+	case kindInt, kindUint:
+		return x + " != 0"
+	}
+	return ""
+}
+
+// encode writes the code appending x of type t to b, where depth names the
+// variables of nested lists and dictionaries. A nil pointer x must be left
+// out by the caller.
+func (g *generator) encode(x string, t *fieldType, depth int) {
+	switch t.kind {
+	case kindString, kindBytes:
+		if t.kind == kindString && t.name != "string" {
+			x = "string(" + x + ")"
+		}
+		g.imports["strconv"] = true
+		g.printf("b = strconv.AppendInt(b, int64(len(%s)), 10)\n", x)
+		g.printf("b = append(b, ':')\n")
+		g.printf("b = append(b, %s...)\n", x)
+
+	case kindBool:
+		g.printf("if %s {\n", x)
+		g.printf("b = append(b, \"i1e\"...)\n")
+		g.printf("} else {\n")
+		g.printf("b = append(b, \"i0e\"...)\n")
+		g.printf("}\n")
+
+	case kindInt:
+		g.imports["strconv"] = true
+		g.printf("b = append(b, 'i')\n")
+		g.printf("b = strconv.AppendInt(b, %s, 10)\n", convert(x, t.name, "int64"))
+		g.printf("b = append(b, 'e')\n")
+
+	case kindUint:
+		g.imports["strconv"] = true
+		g.printf("b = append(b, 'i')\n")
+		g.printf("b = strconv.AppendUint(b, %s, 10)\n", convert(x, t.name, "uint64"))
+		g.printf("b = append(b, 'e')\n")
+
+	case kindStruct:
+		g.printf("b = %s.AppendBencode(b)\n", x)
+
+	case kindPointer:
+		g.encode(deref(x, t), t.elem, depth)
+
+	case kindSlice:
+		e := fmt.Sprintf("e%d", depth)
+		g.printf("b = append(b, 'l')\n")
+		g.printf("for _, %s := range %s {\n", e, x)
+		// nil pointers in lists are left out
+		if t.elem.kind == kindPointer {
+			g.printf("if %s != nil {\n", e)
+			g.encode(e, t.elem, depth+1)
+			g.printf("}\n")
+		} else {
+			g.encode(e, t.elem, depth+1)
+		}
+		g.printf("}\n")
+		g.printf("b = append(b, 'e')\n")
+
+	case kindMap:
+		k, e, keys := fmt.Sprintf("k%d", depth), fmt.Sprintf("e%d", depth), fmt.Sprintf("keys%d", depth)
+		g.imports["sort"] = true
+		g.printf("b = append(b, 'd')\n")
+		g.printf("%s := make([]string, 0, len(%s))\n", keys, x)
+		g.printf("for %s := range %s {\n", k, x)
+		g.printf("%s = append(%s, %s)\n", keys, keys, k)
+		g.printf("}\n")
+		g.printf("sort.Strings(%s)\n", keys)
+		g.printf("for _, %s := range %s {\n", k, keys)
+		g.printf("%s := %s[%s]\n", e, x, k)
+		// entries with nil pointers are left out
+		if t.elem.kind == kindPointer {
+			g.printf("if %s == nil {\n", e)
+			g.printf("continue\n")
+			g.printf("}\n")
+		}
+		g.encode(k, &fieldType{kind: kindString, name: "string"}, depth+1)
+		g.encode(e, t.elem, depth+1)
+		g.printf("}\n")
+		g.printf("b = append(b, 'e')\n")
+	}
+}
+
+// genUnmarshal writes the UnmarshalBencode and UnmarshalBencodeFrom methods
+// of name.
+func (g *generator) genUnmarshal(name string, fields []field) {
+	g.imports["bytes"] = true
+	g.imports[bencodePath] = true
+
+	g.printf("\n// UnmarshalBencode decodes the bencoded dictionary in data into v.\n")
+	g.printf("func (v *%s) UnmarshalBencode(data []byte) error {\n", name)
+	g.printf("return bencode.NewDecoder(bytes.NewReader(data)).Decode(v)\n")
+	g.printf("}\n")
+
+	g.printf("\n// UnmarshalBencodeFrom reads a bencoded dictionary from d into v.\n")
+	g.printf("func (v *%s) UnmarshalBencodeFrom(d *bencode.Decoder) error {\n", name)
+	g.printf("if err := d.BeginDict(); err != nil {\n")
+	g.printf("return err\n")
+	g.printf("}\n")
+	g.printf("for {\n")
+	g.printMore()
+	g.printf("key, err := d.Key()\n")
+	g.printReturn()
+	g.printf("switch key {\n")
+	for _, f := range fields {
+		g.printf("case %s:\n", strconv.Quote(f.key))
+		g.decode("v."+f.path, f.typ, 0)
+	}
+	g.printf("default:\n")
+	g.printf("if err := d.Skip(); err != nil {\n")
+	g.printf("return err\n")
+	g.printf("}\n")
+	g.printf("}\n")
+	g.printf("}\n")
+	g.printf("return d.End()\n")
+	g.printf("}\n")
+}
+
+func (g *generator) printReturn() {
+	g.printf("if err != nil {\n")
+	g.printf("return err\n")
+	g.printf("}\n")
+}
+
+// printMore writes the code that ends a loop over a list or dictionary.
+func (g *generator) printMore() {
+	g.printf("more, err := d.More()\n")
+	g.printReturn()
+	g.printf("if !more {\n")
+	g.printf("break\n")
+	g.printf("}\n")
+}
+
+// decode writes the code reading the next value from d into x of type t,
+// where depth names the variables of nested lists and dictionaries.
+func (g *generator) decode(x string, t *fieldType, depth int) {
+	switch t.kind {
+	case kindString, kindBytes:
+		s := fmt.Sprintf("s%d", depth)
+		g.printf("%s, err := d.ReadString()\n", s)
+		g.printReturn()
+		g.printf("%s = %s\n", x, convert(s, "string", t.name))
+
+	case kindInt:
+		n := fmt.Sprintf("n%d", depth)
+		g.printf("%s, err := d.ReadInt()\n", n)
+		g.printReturn()
+		g.printf("%s = %s\n", x, convert(n, "int64", t.name))
+
+	case kindBool, kindUint:
+		// booleans are read as unsigned integers, as by reflection
+		n := fmt.Sprintf("n%d", depth)
+		g.printf("%s, err := d.ReadUint()\n", n)
+		g.printReturn()
+		if t.kind == kindBool {
+			g.printf("%s = %s != 0\n", x, n)
+		} else {
+			g.printf("%s = %s\n", x, convert(n, "uint64", t.name))
+		}
+
+	case kindStruct:
+		g.printf("if err := %s.UnmarshalBencodeFrom(d); err != nil {\n", x)
+		g.printf("return err\n")
+		g.printf("}\n")
+
+	case kindPointer:
+		g.printf("if %s == nil {\n", x)
+		g.printf("%s = new(%s)\n", x, t.elem.name)
+		g.printf("}\n")
+		g.decode(deref(x, t), t.elem, depth)
+
+	case kindSlice:
+		e := fmt.Sprintf("e%d", depth)
+		g.printf("if err := d.BeginList(); err != nil {\n")
+		g.printf("return err\n")
+		g.printf("}\n")
+		g.printf("%s = %s[:0]\n", x, x)
+		g.printf("for {\n")
+		g.printMore()
+		g.element(e, t.elem, depth+1)
+		g.printf("%s = append(%s, %s)\n", x, x, e)
+		g.printf("}\n")
+		g.printf("if err := d.End(); err != nil {\n")
+		g.printf("return err\n")
+		g.printf("}\n")
+
+	case kindMap:
+		k, e := fmt.Sprintf("k%d", depth), fmt.Sprintf("e%d", depth)
+		g.printf("if err := d.BeginDict(); err != nil {\n")
+		g.printf("return err\n")
+		g.printf("}\n")
+		g.printf("if %s == nil {\n", x)
+		g.printf("%s = make(%s)\n", x, t.name)
+		g.printf("}\n")
+		g.printf("for {\n")
+		g.printMore()
+		g.printf("%s, err := d.Key()\n", k)
+		g.printReturn()
+		g.element(e, t.elem, depth+1)
+		g.printf("%s[%s] = %s\n", x, k, e)
+		g.printf("}\n")
+		g.printf("if err := d.End(); err != nil {\n")
+		g.printf("return err\n")
+		g.printf("}\n")
+	}
+}
+
+// element writes the code declaring the variable e of type t and reading the
+// next value from d into it.
+func (g *generator) element(e string, t *fieldType, depth int) {
+	if t.kind == kindPointer {
+		g.printf("%s := new(%s)\n", e, t.elem.name)
+		g.decode(deref(e, t), t.elem, depth)
+		return
+	}
+	g.printf("var %s %s\n", e, t.name)
+	g.decode(e, t, depth)
+}
+
+// deref returns the expression for the value pointed to by x of the pointer
+// type t. Struct methods are called on the pointer itself.
+func deref(x string, t *fieldType) string {
+	if t.elem.kind == kindStruct {
+		return x
+	}
+	return "*" + x
+}
+
+// convert returns the expression converting x of type from to type to.
+func convert(x, from, to string) string {
+	if from == to {
+		return x
+	}
+	return to + "(" + x + ")"
+}
+
+// isValidTag reports whether key is a valid key for a struct field, as in
+// the bencode package.
+func isValidTag(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, c := range key {
+		if c != ' ' && c != '$' && c != '-' && c != '_' && c != '.' && !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+			return false
+		}
+	}
+	return true
+}
+
+func exprString(expr ast.Expr) string {
+	var buf bytes.Buffer
+	format.Node(&buf, token.NewFileSet(), expr)
+	return buf.String()
+}
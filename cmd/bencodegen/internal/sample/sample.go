@@ -0,0 +1,55 @@
+// Package sample holds the types that bencodegen is tested against. The
+// methods in torrent_bencode.go and their test in torrent_bencode_test.go
+// are generated, and the methods are compared with the output of the
+// bencode package.
+package sample
+
+//go:generate go run github.com/zeebo/bencode/cmd/bencodegen -type Torrent,Info,File,Peer -test
+
+// Port is a named type of a basic type.
+type Port uint16
+
+type Torrent struct {
+	Announce     string         `bencode:"announce"`
+	AnnounceList [][]string     `bencode:"announce-list,omitempty"`
+	Comment      string         `bencode:"comment,omitempty"`
+	CreationDate int64          `bencode:"creation date,omitempty"`
+	Info         Info           `bencode:"info"`
+	URLList      []string       `bencode:"url-list,omitempty"`
+	Extra        map[string]int `bencode:"extra,omitempty"`
+	Peers        []*Peer        `bencode:"peers,omitempty"`
+	Private      *bool          `bencode:"private"`
+	Ignored      string         `bencode:"-"`
+
+	unexported int
+}
+
+type Info struct {
+	Name        string           `bencode:"name"`
+	PieceLength int64            `bencode:"piece length"`
+	Pieces      []byte           `bencode:"pieces"`
+	Length      int64            `bencode:"length,omitempty"`
+	Files       []File           `bencode:"files,omitempty"`
+	Hashes      map[string]*File `bencode:"hashes,omitempty"`
+}
+
+type File struct {
+	Length int64    `bencode:"length"`
+	Path   []string `bencode:"path"`
+}
+
+type Peer struct {
+	Meta
+	IP   string `bencode:"ip"`
+	Port Port   `bencode:"port"`
+	Seed bool   `bencode:"seed,omitempty"`
+	ID   []byte
+
+	Uploaded uint64 `bencode:"uploaded,omitempty"`
+}
+
+// Meta is flattened into Peer.
+type Meta struct {
+	Source string `bencode:"source,omitempty"`
+	Rank   int8   `bencode:"rank"`
+}
@@ -0,0 +1,139 @@
+package sample
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/zeebo/bencode"
+)
+
+// the plain types have the fields of the sample types without their
+// generated methods, so the bencode package encodes them by reflection.
+type (
+	plainTorrent Torrent
+	plainInfo    Info
+	plainFile    File
+	plainPeer    Peer
+)
+
+func sampleTorrent() Torrent {
+	private := true
+	return Torrent{
+		Announce:     "http://tracker/announce",
+		AnnounceList: [][]string{{"http://a"}, {"http://b", "udp://c"}, {}},
+		CreationDate: -5,
+		Info: Info{
+			Name:        "dir",
+			PieceLength: 1 << 18,
+			Pieces:      []byte("\x00\x01\xff"),
+			Files: []File{
+				{Length: 10, Path: []string{"a", "b.txt"}},
+				{Length: 0, Path: nil},
+			},
+			Hashes: map[string]*File{"z": {Length: 1}, "nil": nil, "a": {Path: []string{"x"}}},
+		},
+		Extra: map[string]int{"b": 2, "a": -1, "": 0},
+		Peers: []*Peer{
+			{Meta: Meta{Source: "dht", Rank: -3}, IP: "1.2.3.4", Port: 6881, Seed: true, ID: []byte("id"), Uploaded: math.MaxUint64},
+			nil,
+			{IP: "::1", Port: 65535},
+		},
+		Private: &private,
+		Ignored: "ignored",
+	}
+}
+
+func TestMatchesReflection(t *testing.T) {
+	torrent := sampleTorrent()
+	empty := false
+
+	var cases = []struct {
+		generated bencode.Marshaler
+		plain     interface{}
+	}{
+		{torrent, plainTorrent(torrent)},
+		{Torrent{}, plainTorrent{}},
+		{Torrent{Private: &empty}, plainTorrent{Private: &empty}},
+		{torrent.Info, plainInfo(torrent.Info)},
+		{Info{}, plainInfo{}},
+		{torrent.Info.Files[0], plainFile(torrent.Info.Files[0])},
+		{*torrent.Peers[0], plainPeer(*torrent.Peers[0])},
+		{Peer{}, plainPeer{}},
+	}
+
+	for i, tt := range cases {
+		got, err := tt.generated.MarshalBencode()
+		if err != nil {
+			t.Errorf("#%d: Unexpected err: %v", i, err)
+			continue
+		}
+		expect, err := bencode.EncodeBytes(tt.plain)
+		if err != nil {
+			t.Errorf("#%d: Unexpected err: %v", i, err)
+			continue
+		}
+		if string(got) != string(expect) {
+			t.Errorf("#%d: Val: %q != %q", i, got, expect)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	torrent := sampleTorrent()
+	data, err := torrent.MarshalBencode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// nil pointers are left out, empty lists decode as nil, empty strings as
+	// empty byte slices, and the ignored field is not encoded
+	expect := sampleTorrent()
+	expect.Peers = []*Peer{expect.Peers[0], expect.Peers[2]}
+	delete(expect.Info.Hashes, "nil")
+	expect.AnnounceList[2] = nil
+	expect.Peers[1].ID = []byte{}
+	expect.Ignored = ""
+
+	var got Torrent
+	if err := got.UnmarshalBencode(data); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("got %#v", got)
+	}
+
+	// the generated methods are used by the bencode package, and match its
+	// decoding by reflection
+	var viaDecode Torrent
+	if err := bencode.DecodeBytes(data, &viaDecode); err != nil {
+		t.Fatal(err)
+	}
+	var plain plainTorrent
+	if err := bencode.DecodeBytes(data, &plain); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(viaDecode, got) || !reflect.DeepEqual(Torrent(plain), got) {
+		t.Errorf("got %#v and %#v", viaDecode, plain)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	var cases = []string{
+		`li1ee`,
+		`d8:announcei1ee`,
+		`d4:infod4:name3:fooe`,
+		`d5:peersld4:porti-1eeee`,
+		`d7:privatei-1ee`,
+		`d5:extrad1:a1:bee`,
+		`d4:infod6:hashesd1:xi1eeee`,
+		`d5:peersld8:uploadedi18446744073709551616eeee`,
+	}
+
+	for i, in := range cases {
+		var got Torrent
+		if err := got.UnmarshalBencode([]byte(in)); err == nil {
+			t.Errorf("#%d: Expected err is nil", i)
+		}
+	}
+}
@@ -0,0 +1,651 @@
+// Code generated by bencodegen -type Torrent,Info,File,Peer; DO NOT EDIT.
+
+package sample
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+
+	"github.com/zeebo/bencode"
+)
+
+// MarshalBencode returns the bencoding of v.
+func (v Torrent) MarshalBencode() ([]byte, error) {
+	return v.AppendBencode(nil), nil
+}
+
+// AppendBencode appends the bencoding of v to b and returns the result.
+func (v Torrent) AppendBencode(b []byte) []byte {
+	b = append(b, 'd')
+	b = append(b, "8:announce"...)
+	b = strconv.AppendInt(b, int64(len(v.Announce)), 10)
+	b = append(b, ':')
+	b = append(b, v.Announce...)
+	if len(v.AnnounceList) != 0 {
+		b = append(b, "13:announce-list"...)
+		b = append(b, 'l')
+		for _, e0 := range v.AnnounceList {
+			b = append(b, 'l')
+			for _, e1 := range e0 {
+				b = strconv.AppendInt(b, int64(len(e1)), 10)
+				b = append(b, ':')
+				b = append(b, e1...)
+			}
+			b = append(b, 'e')
+		}
+		b = append(b, 'e')
+	}
+	if len(v.Comment) != 0 {
+		b = append(b, "7:comment"...)
+		b = strconv.AppendInt(b, int64(len(v.Comment)), 10)
+		b = append(b, ':')
+		b = append(b, v.Comment...)
+	}
+	if v.CreationDate != 0 {
+		b = append(b, "13:creation date"...)
+		b = append(b, 'i')
+		b = strconv.AppendInt(b, v.CreationDate, 10)
+		b = append(b, 'e')
+	}
+	if len(v.Extra) != 0 {
+		b = append(b, "5:extra"...)
+		b = append(b, 'd')
+		keys0 := make([]string, 0, len(v.Extra))
+		for k0 := range v.Extra {
+			keys0 = append(keys0, k0)
+		}
+		sort.Strings(keys0)
+		for _, k0 := range keys0 {
+			e0 := v.Extra[k0]
+			b = strconv.AppendInt(b, int64(len(k0)), 10)
+			b = append(b, ':')
+			b = append(b, k0...)
+			b = append(b, 'i')
+			b = strconv.AppendInt(b, int64(e0), 10)
+			b = append(b, 'e')
+		}
+		b = append(b, 'e')
+	}
+	b = append(b, "4:info"...)
+	b = v.Info.AppendBencode(b)
+	if len(v.Peers) != 0 {
+		b = append(b, "5:peers"...)
+		b = append(b, 'l')
+		for _, e0 := range v.Peers {
+			if e0 != nil {
+				b = e0.AppendBencode(b)
+			}
+		}
+		b = append(b, 'e')
+	}
+	if v.Private != nil {
+		b = append(b, "7:private"...)
+		if *v.Private {
+			b = append(b, "i1e"...)
+		} else {
+			b = append(b, "i0e"...)
+		}
+	}
+	if len(v.URLList) != 0 {
+		b = append(b, "8:url-list"...)
+		b = append(b, 'l')
+		for _, e0 := range v.URLList {
+			b = strconv.AppendInt(b, int64(len(e0)), 10)
+			b = append(b, ':')
+			b = append(b, e0...)
+		}
+		b = append(b, 'e')
+	}
+	return append(b, 'e')
+}
+
+// UnmarshalBencode decodes the bencoded dictionary in data into v.
+func (v *Torrent) UnmarshalBencode(data []byte) error {
+	return bencode.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// UnmarshalBencodeFrom reads a bencoded dictionary from d into v.
+func (v *Torrent) UnmarshalBencodeFrom(d *bencode.Decoder) error {
+	if err := d.BeginDict(); err != nil {
+		return err
+	}
+	for {
+		more, err := d.More()
+		if err != nil {
+			return err
+		}
+		if !more {
+			break
+		}
+		key, err := d.Key()
+		if err != nil {
+			return err
+		}
+		switch key {
+		case "announce":
+			s0, err := d.ReadString()
+			if err != nil {
+				return err
+			}
+			v.Announce = s0
+		case "announce-list":
+			if err := d.BeginList(); err != nil {
+				return err
+			}
+			v.AnnounceList = v.AnnounceList[:0]
+			for {
+				more, err := d.More()
+				if err != nil {
+					return err
+				}
+				if !more {
+					break
+				}
+				var e0 []string
+				if err := d.BeginList(); err != nil {
+					return err
+				}
+				e0 = e0[:0]
+				for {
+					more, err := d.More()
+					if err != nil {
+						return err
+					}
+					if !more {
+						break
+					}
+					var e1 string
+					s2, err := d.ReadString()
+					if err != nil {
+						return err
+					}
+					e1 = s2
+					e0 = append(e0, e1)
+				}
+				if err := d.End(); err != nil {
+					return err
+				}
+				v.AnnounceList = append(v.AnnounceList, e0)
+			}
+			if err := d.End(); err != nil {
+				return err
+			}
+		case "comment":
+			s0, err := d.ReadString()
+			if err != nil {
+				return err
+			}
+			v.Comment = s0
+		case "creation date":
+			n0, err := d.ReadInt()
+			if err != nil {
+				return err
+			}
+			v.CreationDate = n0
+		case "extra":
+			if err := d.BeginDict(); err != nil {
+				return err
+			}
+			if v.Extra == nil {
+				v.Extra = make(map[string]int)
+			}
+			for {
+				more, err := d.More()
+				if err != nil {
+					return err
+				}
+				if !more {
+					break
+				}
+				k0, err := d.Key()
+				if err != nil {
+					return err
+				}
+				var e0 int
+				n1, err := d.ReadInt()
+				if err != nil {
+					return err
+				}
+				e0 = int(n1)
+				v.Extra[k0] = e0
+			}
+			if err := d.End(); err != nil {
+				return err
+			}
+		case "info":
+			if err := v.Info.UnmarshalBencodeFrom(d); err != nil {
+				return err
+			}
+		case "peers":
+			if err := d.BeginList(); err != nil {
+				return err
+			}
+			v.Peers = v.Peers[:0]
+			for {
+				more, err := d.More()
+				if err != nil {
+					return err
+				}
+				if !more {
+					break
+				}
+				e0 := new(Peer)
+				if err := e0.UnmarshalBencodeFrom(d); err != nil {
+					return err
+				}
+				v.Peers = append(v.Peers, e0)
+			}
+			if err := d.End(); err != nil {
+				return err
+			}
+		case "private":
+			if v.Private == nil {
+				v.Private = new(bool)
+			}
+			n0, err := d.ReadUint()
+			if err != nil {
+				return err
+			}
+			*v.Private = n0 != 0
+		case "url-list":
+			if err := d.BeginList(); err != nil {
+				return err
+			}
+			v.URLList = v.URLList[:0]
+			for {
+				more, err := d.More()
+				if err != nil {
+					return err
+				}
+				if !more {
+					break
+				}
+				var e0 string
+				s1, err := d.ReadString()
+				if err != nil {
+					return err
+				}
+				e0 = s1
+				v.URLList = append(v.URLList, e0)
+			}
+			if err := d.End(); err != nil {
+				return err
+			}
+		default:
+			if err := d.Skip(); err != nil {
+				return err
+			}
+		}
+	}
+	return d.End()
+}
+
+// MarshalBencode returns the bencoding of v.
+func (v Info) MarshalBencode() ([]byte, error) {
+	return v.AppendBencode(nil), nil
+}
+
+// AppendBencode appends the bencoding of v to b and returns the result.
+func (v Info) AppendBencode(b []byte) []byte {
+	b = append(b, 'd')
+	if len(v.Files) != 0 {
+		b = append(b, "5:files"...)
+		b = append(b, 'l')
+		for _, e0 := range v.Files {
+			b = e0.AppendBencode(b)
+		}
+		b = append(b, 'e')
+	}
+	if len(v.Hashes) != 0 {
+		b = append(b, "6:hashes"...)
+		b = append(b, 'd')
+		keys0 := make([]string, 0, len(v.Hashes))
+		for k0 := range v.Hashes {
+			keys0 = append(keys0, k0)
+		}
+		sort.Strings(keys0)
+		for _, k0 := range keys0 {
+			e0 := v.Hashes[k0]
+			if e0 == nil {
+				continue
+			}
+			b = strconv.AppendInt(b, int64(len(k0)), 10)
+			b = append(b, ':')
+			b = append(b, k0...)
+			b = e0.AppendBencode(b)
+		}
+		b = append(b, 'e')
+	}
+	if v.Length != 0 {
+		b = append(b, "6:length"...)
+		b = append(b, 'i')
+		b = strconv.AppendInt(b, v.Length, 10)
+		b = append(b, 'e')
+	}
+	b = append(b, "4:name"...)
+	b = strconv.AppendInt(b, int64(len(v.Name)), 10)
+	b = append(b, ':')
+	b = append(b, v.Name...)
+	b = append(b, "12:piece length"...)
+	b = append(b, 'i')
+	b = strconv.AppendInt(b, v.PieceLength, 10)
+	b = append(b, 'e')
+	b = append(b, "6:pieces"...)
+	b = strconv.AppendInt(b, int64(len(v.Pieces)), 10)
+	b = append(b, ':')
+	b = append(b, v.Pieces...)
+	return append(b, 'e')
+}
+
+// UnmarshalBencode decodes the bencoded dictionary in data into v.
+func (v *Info) UnmarshalBencode(data []byte) error {
+	return bencode.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// UnmarshalBencodeFrom reads a bencoded dictionary from d into v.
+func (v *Info) UnmarshalBencodeFrom(d *bencode.Decoder) error {
+	if err := d.BeginDict(); err != nil {
+		return err
+	}
+	for {
+		more, err := d.More()
+		if err != nil {
+			return err
+		}
+		if !more {
+			break
+		}
+		key, err := d.Key()
+		if err != nil {
+			return err
+		}
+		switch key {
+		case "files":
+			if err := d.BeginList(); err != nil {
+				return err
+			}
+			v.Files = v.Files[:0]
+			for {
+				more, err := d.More()
+				if err != nil {
+					return err
+				}
+				if !more {
+					break
+				}
+				var e0 File
+				if err := e0.UnmarshalBencodeFrom(d); err != nil {
+					return err
+				}
+				v.Files = append(v.Files, e0)
+			}
+			if err := d.End(); err != nil {
+				return err
+			}
+		case "hashes":
+			if err := d.BeginDict(); err != nil {
+				return err
+			}
+			if v.Hashes == nil {
+				v.Hashes = make(map[string]*File)
+			}
+			for {
+				more, err := d.More()
+				if err != nil {
+					return err
+				}
+				if !more {
+					break
+				}
+				k0, err := d.Key()
+				if err != nil {
+					return err
+				}
+				e0 := new(File)
+				if err := e0.UnmarshalBencodeFrom(d); err != nil {
+					return err
+				}
+				v.Hashes[k0] = e0
+			}
+			if err := d.End(); err != nil {
+				return err
+			}
+		case "length":
+			n0, err := d.ReadInt()
+			if err != nil {
+				return err
+			}
+			v.Length = n0
+		case "name":
+			s0, err := d.ReadString()
+			if err != nil {
+				return err
+			}
+			v.Name = s0
+		case "piece length":
+			n0, err := d.ReadInt()
+			if err != nil {
+				return err
+			}
+			v.PieceLength = n0
+		case "pieces":
+			s0, err := d.ReadString()
+			if err != nil {
+				return err
+			}
+			v.Pieces = []byte(s0)
+		default:
+			if err := d.Skip(); err != nil {
+				return err
+			}
+		}
+	}
+	return d.End()
+}
+
+// MarshalBencode returns the bencoding of v.
+func (v File) MarshalBencode() ([]byte, error) {
+	return v.AppendBencode(nil), nil
+}
+
+// AppendBencode appends the bencoding of v to b and returns the result.
+func (v File) AppendBencode(b []byte) []byte {
+	b = append(b, 'd')
+	b = append(b, "6:length"...)
+	b = append(b, 'i')
+	b = strconv.AppendInt(b, v.Length, 10)
+	b = append(b, 'e')
+	b = append(b, "4:path"...)
+	b = append(b, 'l')
+	for _, e0 := range v.Path {
+		b = strconv.AppendInt(b, int64(len(e0)), 10)
+		b = append(b, ':')
+		b = append(b, e0...)
+	}
+	b = append(b, 'e')
+	return append(b, 'e')
+}
+
+// UnmarshalBencode decodes the bencoded dictionary in data into v.
+func (v *File) UnmarshalBencode(data []byte) error {
+	return bencode.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// UnmarshalBencodeFrom reads a bencoded dictionary from d into v.
+func (v *File) UnmarshalBencodeFrom(d *bencode.Decoder) error {
+	if err := d.BeginDict(); err != nil {
+		return err
+	}
+	for {
+		more, err := d.More()
+		if err != nil {
+			return err
+		}
+		if !more {
+			break
+		}
+		key, err := d.Key()
+		if err != nil {
+			return err
+		}
+		switch key {
+		case "length":
+			n0, err := d.ReadInt()
+			if err != nil {
+				return err
+			}
+			v.Length = n0
+		case "path":
+			if err := d.BeginList(); err != nil {
+				return err
+			}
+			v.Path = v.Path[:0]
+			for {
+				more, err := d.More()
+				if err != nil {
+					return err
+				}
+				if !more {
+					break
+				}
+				var e0 string
+				s1, err := d.ReadString()
+				if err != nil {
+					return err
+				}
+				e0 = s1
+				v.Path = append(v.Path, e0)
+			}
+			if err := d.End(); err != nil {
+				return err
+			}
+		default:
+			if err := d.Skip(); err != nil {
+				return err
+			}
+		}
+	}
+	return d.End()
+}
+
+// MarshalBencode returns the bencoding of v.
+func (v Peer) MarshalBencode() ([]byte, error) {
+	return v.AppendBencode(nil), nil
+}
+
+// AppendBencode appends the bencoding of v to b and returns the result.
+func (v Peer) AppendBencode(b []byte) []byte {
+	b = append(b, 'd')
+	b = append(b, "2:ID"...)
+	b = strconv.AppendInt(b, int64(len(v.ID)), 10)
+	b = append(b, ':')
+	b = append(b, v.ID...)
+	b = append(b, "2:ip"...)
+	b = strconv.AppendInt(b, int64(len(v.IP)), 10)
+	b = append(b, ':')
+	b = append(b, v.IP...)
+	b = append(b, "4:port"...)
+	b = append(b, 'i')
+	b = strconv.AppendUint(b, uint64(v.Port), 10)
+	b = append(b, 'e')
+	b = append(b, "4:rank"...)
+	b = append(b, 'i')
+	b = strconv.AppendInt(b, int64(v.Meta.Rank), 10)
+	b = append(b, 'e')
+	if v.Seed {
+		b = append(b, "4:seed"...)
+		if v.Seed {
+			b = append(b, "i1e"...)
+		} else {
+			b = append(b, "i0e"...)
+		}
+	}
+	if len(v.Meta.Source) != 0 {
+		b = append(b, "6:source"...)
+		b = strconv.AppendInt(b, int64(len(v.Meta.Source)), 10)
+		b = append(b, ':')
+		b = append(b, v.Meta.Source...)
+	}
+	if v.Uploaded != 0 {
+		b = append(b, "8:uploaded"...)
+		b = append(b, 'i')
+		b = strconv.AppendUint(b, v.Uploaded, 10)
+		b = append(b, 'e')
+	}
+	return append(b, 'e')
+}
+
+// UnmarshalBencode decodes the bencoded dictionary in data into v.
+func (v *Peer) UnmarshalBencode(data []byte) error {
+	return bencode.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// UnmarshalBencodeFrom reads a bencoded dictionary from d into v.
+func (v *Peer) UnmarshalBencodeFrom(d *bencode.Decoder) error {
+	if err := d.BeginDict(); err != nil {
+		return err
+	}
+	for {
+		more, err := d.More()
+		if err != nil {
+			return err
+		}
+		if !more {
+			break
+		}
+		key, err := d.Key()
+		if err != nil {
+			return err
+		}
+		switch key {
+		case "ID":
+			s0, err := d.ReadString()
+			if err != nil {
+				return err
+			}
+			v.ID = []byte(s0)
+		case "ip":
+			s0, err := d.ReadString()
+			if err != nil {
+				return err
+			}
+			v.IP = s0
+		case "port":
+			n0, err := d.ReadUint()
+			if err != nil {
+				return err
+			}
+			v.Port = Port(n0)
+		case "rank":
+			n0, err := d.ReadInt()
+			if err != nil {
+				return err
+			}
+			v.Meta.Rank = int8(n0)
+		case "seed":
+			n0, err := d.ReadUint()
+			if err != nil {
+				return err
+			}
+			v.Seed = n0 != 0
+		case "source":
+			s0, err := d.ReadString()
+			if err != nil {
+				return err
+			}
+			v.Meta.Source = s0
+		case "uploaded":
+			n0, err := d.ReadUint()
+			if err != nil {
+				return err
+			}
+			v.Uploaded = n0
+		default:
+			if err := d.Skip(); err != nil {
+				return err
+			}
+		}
+	}
+	return d.End()
+}
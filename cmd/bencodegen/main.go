@@ -0,0 +1,95 @@
+// Bencodegen generates methods that encode and decode struct types without
+// reflection, for use where the reflection of the bencode package is too
+// slow. Given the name of a struct type T in the package in the current
+// directory, such as with
+//
+//	//go:generate bencodegen -type Torrent,Info
+//
+// it writes a file declaring
+//
+//	func (v T) MarshalBencode() ([]byte, error)
+//	func (v T) AppendBencode(b []byte) []byte
+//	func (v *T) UnmarshalBencode(data []byte) error
+//	func (v *T) UnmarshalBencodeFrom(d *bencode.Decoder) error
+//
+// The keys of each dictionary are sorted when the code is generated, and the
+// output is the same as bencode.EncodeBytes gives for the type without the
+// methods. The fields are read from their bencode tags as the bencode package
+// reads them, with the omitempty option, and may be strings, byte slices,
+// booleans, integers, other struct types named by -type, and pointers to,
+// slices of and maps with string keys of those. Untagged embedded structs
+// from the same package are flattened into their parent. Any other type or
+// tag option, such as compact or the time options, is an error.
+//
+// The generated code does not consult a bencode.Registry, and unknown keys
+// are skipped even when a Decoder disallows unknown fields.
+//
+// With -test it also writes a test of the methods next to the output file,
+// named like it with a _test.go suffix, that compares them with the bencode
+// package for the zero value of each type and for a value with every field
+// set.
+//
+// Usage:
+//
+//	bencodegen -type T[,T...] [-output file] [-test] [directory]
+//
+// The default output file is t_bencode.go in the directory, where t is the
+// first type name in lower case.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	typeNames = flag.String("type", "", "comma-separated list of type names; must be set")
+	output    = flag.String("output", "", "output file name; default srcdir/<type>_bencode.go")
+	withTest  = flag.Bool("test", false, "also write a test of the methods to the output file name with a _test.go suffix")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage of bencodegen:\n")
+	fmt.Fprintf(os.Stderr, "\tbencodegen -type T[,T...] [-output file] [-test] [directory]\n")
+	fmt.Fprintf(os.Stderr, "Flags:\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("bencodegen: ")
+	flag.Usage = usage
+	flag.Parse()
+	if *typeNames == "" || flag.NArg() > 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	types := strings.Split(*typeNames, ",")
+
+	dir := "."
+	if flag.NArg() == 1 {
+		dir = flag.Arg(0)
+	}
+
+	src, test, err := generate(dir, types)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	name := *output
+	if name == "" {
+		name = filepath.Join(dir, strings.ToLower(types[0])+"_bencode.go")
+	}
+	if err := os.WriteFile(name, src, 0644); err != nil {
+		log.Fatal(err)
+	}
+	if *withTest {
+		if err := os.WriteFile(strings.TrimSuffix(name, ".go")+"_test.go", test, 0644); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGolden(t *testing.T) {
+	src, test, err := generate("internal/sample", []string{"Torrent", "Info", "File", "Peer"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, got := range map[string][]byte{
+		"internal/sample/torrent_bencode.go":      src,
+		"internal/sample/torrent_bencode_test.go": test,
+	} {
+		expect, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != string(expect) {
+			t.Errorf("generated code differs from %s; run go generate there", name)
+		}
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	var cases = []string{
+		// unsupported options
+		"type T struct { A int64 `bencode:\"a,unix\"` }",
+		"type T struct { A []byte `bencode:\"a,compact\"` }",
+		"type T struct { A string `bencode:\"a,omitempty,string\"` }",
+		// unsupported types
+		"type T struct { A float64 }",
+		"type T struct { A interface{} }",
+		"type T struct { A [20]byte }",
+		"type T struct { A map[int]string }",
+		"type T struct { A **int }",
+		"type T struct { A time.Time }",
+		"type T struct { A U }\ntype U struct{}",
+		"type T struct { A U }\ntype U []string",
+		// types that encode themselves
+		"type T struct { A U }\ntype U string\nfunc (U) MarshalText() ([]byte, error) { return nil, nil }",
+		// embedded fields that are not flattened the same way
+		"type T struct { *U }\ntype U struct{}",
+		"type T struct { U `bencode:\"u\"` }\ntype U struct{}",
+		"type T struct { U }\ntype U string",
+		// invalid and repeated keys
+		"type T struct { A int `bencode:\"a/b\"` }",
+		"type T struct { A int `bencode:\"B\"`; B int }",
+		"type T struct { U; A int `bencode:\"a\"` }\ntype U struct { A int `bencode:\"a\"` }",
+		// not a struct
+		"type T int",
+	}
+
+	for i, src := range cases {
+		dir := t.TempDir()
+		src = "package p\n\n" + src + "\n"
+		if err := os.WriteFile(filepath.Join(dir, "p.go"), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := generate(dir, []string{"T"}); err == nil {
+			t.Errorf("#%d: Expected err is nil", i)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package bencode
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"reflect"
+)
+
+var (
+	reflectAddrPortType      = reflect.TypeOf(netip.AddrPort{})
+	reflectAddrPortSliceType = reflect.TypeOf([]netip.AddrPort(nil))
+	reflectNodeType          = reflect.TypeOf(Node{})
+	reflectNodeSliceType     = reflect.TypeOf([]Node(nil))
+)
+
+// Node is the contact information of a DHT node as described in BEP 5.
+// With the ,compact tag option, a Node or []Node field is encoded as a
+// string of 26-byte records, or 38-byte records with ,compact,ipv6.
+type Node struct {
+	ID   [20]byte
+	Addr netip.AddrPort
+}
+
+// compactSize returns the size of the records for the type t, or 0 if the
+// type cannot be compact.
+//
+// The ,compact tag option encodes netip.AddrPort and Node fields, and
+// slices of them, as a string of fixed-size binary records as described in
+// BEP 23 and BEP 5: an address of 4 bytes, or 16 bytes with the ,ipv6
+// option, followed by a big-endian port of 2 bytes, with a Node's ID first.
+// IPv4 addresses mapped into IPv6 are written as IPv4 without ,ipv6.
+func compactSize(t reflect.Type, opts tagOptions) int {
+	size := 6
+	if opts.Contains("ipv6") {
+		size = 18
+	}
+	switch t {
+	case reflectAddrPortType, reflectAddrPortSliceType:
+		return size
+	case reflectNodeType, reflectNodeSliceType:
+		return 20 + size
+	}
+	return 0
+}
+
+func (e *Encoder) encodeCompact(val reflect.Value, opts tagOptions) error {
+	v := indirect(val, false)
+	if !v.IsValid() {
+		return nil
+	}
+	size := compactSize(v.Type(), opts)
+	if size == 0 {
+		return fmt.Errorf("Can't encode type %s with the compact option", v.Type())
+	}
+
+	e.scratch = e.scratch[:0]
+	var err error
+	switch v.Type() {
+	case reflectAddrPortType:
+		e.scratch, err = appendCompactAddr(e.scratch, v.Interface().(netip.AddrPort), size)
+	case reflectAddrPortSliceType:
+		for _, ap := range v.Interface().([]netip.AddrPort) {
+			if e.scratch, err = appendCompactAddr(e.scratch, ap, size); err != nil {
+				break
+			}
+		}
+	case reflectNodeType:
+		e.scratch, err = appendCompactNode(e.scratch, v.Interface().(Node), size)
+	case reflectNodeSliceType:
+		for _, n := range v.Interface().([]Node) {
+			if e.scratch, err = appendCompactNode(e.scratch, n, size); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(e.w, "%d:", len(e.scratch)); err != nil {
+		return err
+	}
+	_, err = e.w.Write(e.scratch)
+	return err
+}
+
+// appendCompactAddr appends the record for ap of the given size to dst.
+func appendCompactAddr(dst []byte, ap netip.AddrPort, size int) ([]byte, error) {
+	addr := ap.Addr()
+	if size == 6 {
+		addr = addr.Unmap()
+		if !addr.Is4() {
+			return dst, fmt.Errorf("Can't encode %s as a compact IPv4 address", ap)
+		}
+	} else if !addr.Is6() {
+		return dst, fmt.Errorf("Can't encode %s as a compact IPv6 address", ap)
+	}
+	dst = append(dst, addr.AsSlice()...)
+	return binary.BigEndian.AppendUint16(dst, ap.Port()), nil
+}
+
+func appendCompactNode(dst []byte, n Node, size int) ([]byte, error) {
+	return appendCompactAddr(append(dst, n.ID[:]...), n.Addr, size-20)
+}
+
+func (d *Decoder) decodeCompact(val reflect.Value, opts tagOptions) error {
+	v := indirect(val, true)
+	size := compactSize(v.Type(), opts)
+	if size == 0 {
+		return fmt.Errorf("Can't decode into type %s with the compact option", v.Type())
+	}
+
+	var data []byte
+	if err := d.decodeString(reflect.ValueOf(&data).Elem()); err != nil {
+		return err
+	}
+	if len(data)%size != 0 {
+		return fmt.Errorf("compact string of %d bytes is not a multiple of %d", len(data), size)
+	}
+
+	switch v.Type() {
+	case reflectAddrPortType:
+		if len(data) != size {
+			return fmt.Errorf("compact string of %d bytes does not hold one address", len(data))
+		}
+		v.Set(reflect.ValueOf(parseCompactAddr(data)))
+
+	case reflectAddrPortSliceType:
+		addrs := make([]netip.AddrPort, 0, len(data)/size)
+		for ; len(data) > 0; data = data[size:] {
+			addrs = append(addrs, parseCompactAddr(data[:size]))
+		}
+		v.Set(reflect.ValueOf(addrs))
+
+	case reflectNodeType:
+		if len(data) != size {
+			return fmt.Errorf("compact string of %d bytes does not hold one node", len(data))
+		}
+		v.Set(reflect.ValueOf(parseCompactNode(data)))
+
+	case reflectNodeSliceType:
+		nodes := make([]Node, 0, len(data)/size)
+		for ; len(data) > 0; data = data[size:] {
+			nodes = append(nodes, parseCompactNode(data[:size]))
+		}
+		v.Set(reflect.ValueOf(nodes))
+	}
+	return nil
+}
+
+// parseCompactAddr parses a record of 6 or 18 bytes.
+func parseCompactAddr(rec []byte) netip.AddrPort {
+	addr, _ := netip.AddrFromSlice(rec[:len(rec)-2])
+	return netip.AddrPortFrom(addr, binary.BigEndian.Uint16(rec[len(rec)-2:]))
+}
+
+func parseCompactNode(rec []byte) Node {
+	var n Node
+	copy(n.ID[:], rec)
+	n.Addr = parseCompactAddr(rec[20:])
+	return n
+}
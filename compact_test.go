@@ -0,0 +1,132 @@
+package bencode
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+func TestCompactRoundTrip(t *testing.T) {
+	type peers struct {
+		Peers  []netip.AddrPort `bencode:"peers,compact"`
+		Peers6 []netip.AddrPort `bencode:"peers6,compact,ipv6"`
+		Self   *netip.AddrPort  `bencode:"self,compact,omitempty"`
+	}
+	type nodes struct {
+		ID     string `bencode:"id"`
+		Nodes  []Node `bencode:"nodes,compact"`
+		Nodes6 []Node `bencode:"nodes6,compact,ipv6"`
+		Target Node   `bencode:"target,compact"`
+	}
+	type optional struct {
+		One  netip.AddrPort `bencode:"one,compact,omitempty"`
+		Node Node           `bencode:"node,compact,omitempty"`
+	}
+
+	self := netip.MustParseAddrPort("10.0.0.1:6881")
+	id := [20]byte{'a', 'b', 'c', 19: 'z'}
+	idStr := "abc" + string(make([]byte, 16)) + "z"
+
+	type testCase struct {
+		val    interface{}
+		expect string
+	}
+
+	var cases = []testCase{
+		{&peers{
+			Peers: []netip.AddrPort{
+				netip.MustParseAddrPort("1.2.3.4:6881"),
+				netip.MustParseAddrPort("255.0.0.1:1"),
+			},
+			Peers6: []netip.AddrPort{netip.MustParseAddrPort("[2001:db8::1]:256")},
+			Self:   &self,
+		}, "d5:peers12:\x01\x02\x03\x04\x1a\xe1\xff\x00\x00\x01\x00\x01" +
+			"6:peers618:\x20\x01\x0d\xb8" + string(make([]byte, 11)) + "\x01\x01\x00" +
+			"4:self6:\x0a\x00\x00\x01\x1a\xe1e"},
+		{&peers{}, "d5:peers0:6:peers60:e"},
+		{&nodes{
+			ID:     idStr,
+			Nodes:  []Node{{id, netip.MustParseAddrPort("1.2.3.4:80")}},
+			Target: Node{id, netip.MustParseAddrPort("5.6.7.8:443")},
+		}, "d2:id20:" + idStr +
+			"5:nodes26:" + idStr + "\x01\x02\x03\x04\x00\x50" +
+			"6:nodes60:" +
+			"6:target26:" + idStr + "\x05\x06\x07\x08\x01\xbbe"},
+		{&optional{}, "de"},
+		{&optional{One: self, Node: Node{Addr: self}},
+			"d4:node26:" + string(make([]byte, 20)) + "\x0a\x00\x00\x01\x1a\xe1" +
+				"3:one6:\x0a\x00\x00\x01\x1a\xe1e"},
+	}
+
+	for i, tt := range cases {
+		got, err := EncodeString(tt.val)
+		if err != nil {
+			t.Errorf("#%d: Unexpected err: %v", i, err)
+			continue
+		}
+		if got != tt.expect {
+			t.Errorf("#%d: Val: %q != %q", i, got, tt.expect)
+			continue
+		}
+
+		back := reflect.New(reflect.TypeOf(tt.val).Elem())
+		if err := DecodeString(got, back.Interface()); err != nil {
+			t.Errorf("#%d: Unexpected err: %v", i, err)
+			continue
+		}
+		again, _ := EncodeString(back.Interface())
+		if again != got {
+			t.Errorf("#%d: round trip: %q != %q", i, again, got)
+		}
+	}
+}
+
+func TestCompactErrors(t *testing.T) {
+	type peers struct {
+		Peers  []netip.AddrPort `bencode:"peers,compact"`
+		Peers6 []netip.AddrPort `bencode:"peers6,compact,ipv6"`
+		Self   netip.AddrPort   `bencode:"self,compact"`
+	}
+	type bad struct {
+		S string `bencode:"s,compact"`
+	}
+
+	var encodes = []interface{}{
+		peers{Peers: []netip.AddrPort{netip.MustParseAddrPort("[2001:db8::1]:1")}},
+		peers{Peers6: []netip.AddrPort{netip.MustParseAddrPort("1.2.3.4:1")}},
+		bad{"x"},
+	}
+	for i, val := range encodes {
+		if _, err := EncodeString(val); err == nil {
+			t.Errorf("#%d: Expected err is nil", i)
+		}
+	}
+
+	// IPv4 addresses mapped into IPv6 are written as IPv4
+	var mapped struct {
+		Peers []netip.AddrPort `bencode:"peers,compact"`
+	}
+	mapped.Peers = []netip.AddrPort{netip.MustParseAddrPort("[::ffff:1.2.3.4]:1")}
+	if got, err := EncodeString(mapped); err != nil || got != "d5:peers6:\x01\x02\x03\x04\x00\x01e" {
+		t.Errorf("got %q, %v", got, err)
+	}
+
+	var decodes = []string{
+		"d5:peers5:\x01\x02\x03\x04\x00e",
+		"d6:peers617:" + string(make([]byte, 17)) + "e",
+		"d4:self12:" + string(make([]byte, 12)) + "e",
+		"d5:peersli1eee",
+		"d1:s0:e",
+	}
+	for i, in := range decodes {
+		var p struct {
+			Peers  []netip.AddrPort `bencode:"peers,compact"`
+			Peers6 []netip.AddrPort `bencode:"peers6,compact,ipv6"`
+			Self   netip.AddrPort   `bencode:"self,compact"`
+			S      string           `bencode:"s,compact"`
+		}
+		if err := DecodeString(in, &p); err == nil {
+			t.Errorf("#%d: Expected err is nil", i)
+		}
+	}
+}
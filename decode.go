@@ -3,6 +3,7 @@ package bencode
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding"
 	"errors"
 	"fmt"
@@ -10,6 +11,7 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 )
 
 var (
@@ -25,13 +27,33 @@ type Unmarshaler interface {
 	UnmarshalBencode([]byte) error
 }
 
+// UnmarshalerFrom is the interface implemented by types that can read
+// themselves from a Decoder as a single value, using its BeginList,
+// BeginDict, More, Key, End and Read methods or Decode, without the value
+// being buffered first. It is used in preference to Unmarshaler.
+type UnmarshalerFrom interface {
+	UnmarshalBencodeFrom(*Decoder) error
+}
+
 // A Decoder reads and decodes bencoded data from an input stream.
 type Decoder struct {
+	src           io.Reader // the reader passed to NewDecoder
 	r             *bufio.Reader
-	raw           bool
+	scan          scanner // finds the end of values that are not decoded
 	buf           []byte
 	n             int
 	failUnordered bool
+	failDuplicate bool
+	failUnknown   bool
+	floatPolicy   FloatPolicy
+	maxDepth      int   // limit on nesting, or 0
+	maxStringLen  int64 // limit on string lengths, or 0
+	depth         int   // number of containers being decoded
+	ifaceBytes    bool  // store strings in interfaces as []byte
+	ifaceValues   bool  // store Values in interfaces
+	registry      *Registry
+	stack         []readFrame     // lists and dictionaries opened by BeginList and BeginDict
+	ctx           context.Context // checked between values by DecodeContext
 }
 
 // SetFailOnUnorderedKeys will cause the decoder to fail when encountering
@@ -40,51 +62,111 @@ func (d *Decoder) SetFailOnUnorderedKeys(fail bool) {
 	d.failUnordered = fail
 }
 
+// SetFailOnDuplicateKeys will cause the decoder to fail when a dictionary
+// has the same key more than once. The default is to not fail, and the last
+// value of the key is the one that is kept.
+func (d *Decoder) SetFailOnDuplicateKeys(fail bool) {
+	d.failDuplicate = fail
+}
+
+// SetFloatPolicy sets how the decoder stores values into floating-point
+// fields. Under any policy other than FloatReject, bencode integers and
+// strings holding a decimal number are accepted. The default is FloatReject.
+func (d *Decoder) SetFloatPolicy(p FloatPolicy) {
+	d.floatPolicy = p
+}
+
 // BytesParsed returns the number of bytes that have actually been parsed
 func (d *Decoder) BytesParsed() int {
 	return d.n
 }
 
-// read also writes into the buffer when d.raw is set.
-func (d *Decoder) read(p []byte) (n int, err error) {
-	n, err = d.r.Read(p)
-	if d.raw {
-		d.buf = append(d.buf, p[:n]...)
-	}
-	d.n += n
-	return
-}
-
-// readBytes also writes into the buffer when d.raw is set.
 func (d *Decoder) readBytes(delim byte) (line []byte, err error) {
 	line, err = d.r.ReadBytes(delim)
-	if d.raw {
-		d.buf = append(d.buf, line...)
-	}
 	d.n += len(line)
 	return
 }
 
-// readByte also writes into the buffer when d.raw is set.
 func (d *Decoder) readByte() (b byte, err error) {
 	b, err = d.r.ReadByte()
-	if d.raw {
-		d.buf = append(d.buf, b)
-	}
 	d.n++
 	return
 }
 
-// readFull also writes into the buffer when d.raw is set.
 func (d *Decoder) readFull(p []byte) (n int, err error) {
 	n, err = io.ReadFull(d.r, p)
-	if d.raw {
-		d.buf = append(d.buf, p[:n]...)
-	}
 	d.n += n
 	return
 }
 
+// readRaw reads the next value without decoding it and returns its bencode,
+// which is only valid until the next call to readRaw.
+func (d *Decoder) readRaw() ([]byte, error) {
+	d.buf = d.buf[:0]
+	d.scan.reset(int64(d.n))
+	for {
+		// copy string bodies in bulk, a bounded chunk at a time
+		// so that a bogus length cannot exhaust memory by itself.
+		if d.scan.state == stateStrBody {
+			if d.ctx != nil {
+				if err := d.ctx.Err(); err != nil {
+					return nil, err
+				}
+			}
+
+			n := d.scan.strlen
+			if n > 64<<10 {
+				n = 64 << 10
+			}
+			start := len(d.buf)
+			d.buf = append(d.buf, make([]byte, n)...)
+			if _, err := d.readFull(d.buf[start:]); err != nil {
+				// the string has begun, so the input ends too soon
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				return nil, err
+			}
+			if d.scan.skip(n) == scanEnd {
+				return d.buf, nil
+			}
+			continue
+		}
+
+		c, err := d.r.ReadByte()
+		if err == io.EOF && len(d.buf) > 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, err
+		}
+		d.n++
+		d.buf = append(d.buf, c)
+
+		switch d.scan.step(c) {
+		case scanEnd:
+			return d.buf, nil
+		case scanError:
+			return nil, d.scan.err
+		}
+		if err := d.checkLimits(d.depth+d.scan.depth, d.scan.strLength()); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// checkLimits returns an error if the nesting depth or a string length
+// goes past the limits of the decoder.
+func (d *Decoder) checkLimits(depth int, strlen int64) error {
+	if d.maxDepth > 0 && depth > d.maxDepth {
+		return fmt.Errorf("nesting depth exceeds the limit of %d", d.maxDepth)
+	}
+	if d.maxStringLen > 0 && strlen > d.maxStringLen {
+		return fmt.Errorf("string length %d exceeds the limit of %d", strlen, d.maxStringLen)
+	}
+	return nil
+}
+
 func (d *Decoder) peekByte() (b byte, err error) {
 	ch, err := d.r.Peek(1)
 	if err != nil {
@@ -96,7 +178,7 @@ func (d *Decoder) peekByte() (b byte, err error) {
 
 // NewDecoder returns a new decoder that reads from r
 func NewDecoder(r io.Reader) *Decoder {
-	return &Decoder{r: bufio.NewReader(r)}
+	return &Decoder{src: r, r: bufio.NewReader(r)}
 }
 
 // Decode reads the bencoded value from its input and stores it in the value pointed to by val.
@@ -106,18 +188,62 @@ func NewDecoder(r io.Reader) *Decoder {
 // 	string for bencoded strings
 // 	[]interface{} for bencoded lists
 // 	map[string]interface{} for bencoded dicts
-// To unmarshal bencode into a value implementing the Unmarshaler interface,
+// To unmarshal bencode into a value implementing the UnmarshalerFrom interface,
+// Unmarshal calls that value's UnmarshalBencodeFrom method.
+// Otherwise, if the value implements the Unmarshaler interface,
 // Unmarshal calls that value's UnmarshalBencode method.
 // Otherwise, if the value implements encoding.TextUnmarshaler
 // and the input is a bencode string, Unmarshal calls that value's
 // UnmarshalText method with the decoded form of the string.
+// Inside a list or dictionary opened with BeginList or BeginDict, Decode
+// reads the next element or dictionary value.
 func (d *Decoder) Decode(val interface{}) error {
 	rv := reflect.ValueOf(val)
 	if rv.Kind() != reflect.Ptr || rv.IsNil() {
 		return errors.New("Unwritable type passed into decode")
 	}
+	if err := d.beforeValue(); err != nil {
+		return err
+	}
 
-	return d.decodeInto(rv)
+	return d.decodeInto(rv, "")
+}
+
+// DecodeContext is like Decode, but stops early if ctx is done before the
+// value has been read. Cancellation is checked before each value, and if
+// the reader passed to NewDecoder has a SetReadDeadline method, as network
+// connections do, a read blocked when ctx is done is interrupted by setting
+// its read deadline to the past, where it is left. The error then wraps
+// ctx.Err() and reports the number of bytes parsed. It is also returned if
+// the deadline was set just after the value was read, so that a reader
+// left with a deadline in the past is never reported as a success. After a
+// cancellation the position of d in its input is unknown, so d should not
+// be used again.
+func (d *Decoder) DecodeContext(ctx context.Context, val interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("decode canceled at offset %d: %w", d.n, err)
+	}
+
+	stop := func() bool { return true }
+	if dl, ok := d.src.(interface{ SetReadDeadline(time.Time) error }); ok {
+		stop = context.AfterFunc(ctx, func() {
+			dl.SetReadDeadline(time.Now())
+		})
+	}
+
+	d.ctx = ctx
+	defer func() { d.ctx = nil }()
+
+	err := d.Decode(val)
+
+	// the deadline was set if stop is too late to prevent it
+	if !stop() && err == nil {
+		err = ctx.Err()
+	}
+	if err != nil && ctx.Err() != nil {
+		return fmt.Errorf("decode canceled at offset %d: %w", d.n, ctx.Err())
+	}
+	return err
 }
 
 // DecodeString reads the data in the string and stores it into the value pointed to by val.
@@ -163,55 +289,93 @@ func indirect(v reflect.Value, alloc bool) reflect.Value {
 	}
 }
 
-func (d *Decoder) decodeInto(val reflect.Value) (err error) {
-	var v reflect.Value
-	if d.raw {
-		v = val
-	} else {
-		var (
-			unmarshaler     Unmarshaler
-			textUnmarshaler encoding.TextUnmarshaler
-		)
-		unmarshaler, textUnmarshaler, v = d.indirect(val)
-
-		// if we're decoding into an Unmarshaler,
-		// we pass on the next bencode value to this value instead,
-		// so it can decide what to do with it.
-		if unmarshaler != nil {
-			var x RawMessage
-			if err := d.decodeInto(reflect.ValueOf(&x)); err != nil {
-				return err
-			}
-			return unmarshaler.UnmarshalBencode([]byte(x))
+// decodeInto reads the next value into val, using the options of the struct
+// field tag that val comes from, if any.
+func (d *Decoder) decodeInto(val reflect.Value, opts tagOptions) (err error) {
+	if opts.Contains("compact") {
+		return d.decodeCompact(val, opts)
+	}
+
+	if d.ctx != nil {
+		if err := d.ctx.Err(); err != nil {
+			return err
 		}
+	}
 
-		// if we're decoding into an TextUnmarshaler,
-		// we'll assume that the bencode value is a string,
-		// we decode it as such and pass the result onto the unmarshaler.
-		if textUnmarshaler != nil {
-			var b []byte
-			ref := reflect.ValueOf(&b)
-			if err := d.decodeString(reflect.Indirect(ref)); err != nil {
-				return err
-			}
-			return textUnmarshaler.UnmarshalText(b)
+	// choose the type to store in the interface of a Union by its key
+	if u, iv := unionTarget(d.registry, val); u != nil {
+		return d.decodeUnion(u, iv)
+	}
+
+	unmarshalerFrom, unmarshaler, textUnmarshaler, v := d.indirect(val)
+
+	// let an UnmarshalerFrom read the next value itself
+	if unmarshalerFrom != nil {
+		return d.unmarshalFrom(unmarshalerFrom)
+	}
+
+	// if we're decoding into an Unmarshaler,
+	// we pass on the next bencode value to this value instead,
+	// so it can decide what to do with it.
+	if unmarshaler != nil {
+		raw, err := d.readRaw()
+		if err != nil {
+			return err
+		}
+		return unmarshaler.UnmarshalBencode(raw)
+	}
+
+	// if we're decoding into an TextUnmarshaler,
+	// we'll assume that the bencode value is a string,
+	// we decode it as such and pass the result onto the unmarshaler.
+	if textUnmarshaler != nil {
+		var b []byte
+		ref := reflect.ValueOf(&b)
+		if err := d.decodeString(reflect.Indirect(ref)); err != nil {
+			return err
+		}
+		return textUnmarshaler.UnmarshalText(b)
+	}
+
+	// if we're decoding into a RawMessage, the scanner finds the end of the
+	// next value and we store a copy of its bytes.
+	if _, ok := v.Interface().(RawMessage); ok {
+		raw, err := d.readRaw()
+		if err != nil {
+			return err
 		}
+		v.SetBytes(append([]byte(nil), raw...))
+		return nil
+	}
+
+	// only an empty interface can hold the values decoded without a Union
+	if v.Kind() == reflect.Interface && v.NumMethod() > 0 {
+		return fmt.Errorf("Can't decode into non-empty interface %s without a Union", v.Type())
+	}
 
-		// if we're decoding into a RawMessage set raw to true for the rest of
-		// the call stack, and switch out the value with an interface{}.
-		if _, ok := v.Interface().(RawMessage); ok {
-			v = reflect.Value{} // explicitly make v invalid
+	// read times and durations in the units asked for by the tag options
+	switch v.Type() {
+	case reflectTimeType:
+		return d.decodeTime(v, opts)
+	case reflectDurationType:
+		if ok, err := d.decodeDuration(v, opts); ok {
+			return err
+		}
+	}
 
-			// set d.raw for the lifetime of this function call, and set the raw
-			// message when the function is exiting.
-			d.buf = d.buf[:0]
-			d.raw = true
-			defer func() {
-				d.raw = false
-				v := indirect(val, true)
-				v.SetBytes(append([]byte(nil), d.buf...))
-			}()
+	// store a Value in an empty interface if asked to
+	if d.ifaceValues && v.Kind() == reflect.Interface && v.NumMethod() == 0 {
+		var x Value
+		if err := d.decodeInto(reflect.ValueOf(&x).Elem(), ""); err != nil {
+			return err
 		}
+		v.Set(reflect.ValueOf(x))
+		return nil
+	}
+
+	// read a list into the fields of a struct in order
+	if opts.Contains("tuple") && v.Kind() == reflect.Struct {
+		return d.decodeTuple(v)
 	}
 
 	next, err := d.peekByte()
@@ -219,15 +383,20 @@ func (d *Decoder) decodeInto(val reflect.Value) (err error) {
 		return
 	}
 
+	// a WriterString holds only a string, not the fields of its struct
+	if v.Type() == reflectWriterStringType && (next < '0' || next > '9') {
+		return fmt.Errorf("Cannot store %q into WriterString", next)
+	}
+
 	switch next {
 	case 'i':
 		err = d.decodeInt(v)
 	case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
 		err = d.decodeString(v)
 	case 'l':
-		err = d.decodeList(v)
+		err = d.decodeList(v, opts)
 	case 'd':
-		err = d.decodeDict(v)
+		err = d.decodeDict(v, opts)
 	default:
 		err = errors.New("Invalid input")
 	}
@@ -246,9 +415,12 @@ func (d *Decoder) decodeInt(v reflect.Value) error {
 	}
 
 	line, err := d.readBytes('e')
-	if err != nil || d.raw {
+	if err != nil {
 		return err
 	}
+	if !isIntText(line[:len(line)-1]) {
+		return fmt.Errorf("invalid integer %q", line[:len(line)-1])
+	}
 
 	digits := string(line[:len(line)-1])
 
@@ -279,6 +451,8 @@ func (d *Decoder) decodeInt(v reflect.Value) error {
 			return err
 		}
 		v.SetBool(n != 0)
+	case reflect.Float32, reflect.Float64:
+		return d.setFloatInt(v, digits)
 	}
 
 	return nil
@@ -290,6 +464,31 @@ func (d *Decoder) decodeString(v reflect.Value) error {
 	if err != nil {
 		return err
 	}
+	if !isDigits(line[:len(line)-1]) {
+		return fmt.Errorf("invalid string length %q", line[:len(line)-1])
+	}
+	if d.maxStringLen > 0 {
+		l64, err := strconv.ParseInt(string(line[:len(line)-1]), 10, 64)
+		if err != nil {
+			return err
+		}
+		if err := d.checkLimits(0, l64); err != nil {
+			return err
+		}
+	}
+
+	// a WriterString receives the contents as they are read,
+	// so its length is not limited by what fits in memory.
+	if v.Type() == reflectWriterStringType {
+		l64, err := strconv.ParseInt(string(line[:len(line)-1]), 10, 64)
+		if err != nil {
+			return err
+		}
+		if l64 < 0 {
+			return fmt.Errorf("invalid negative string length: %d", l64)
+		}
+		return d.decodeWriterString(v.Addr().Interface().(*WriterString), l64)
+	}
 
 	// parse it into an int for making a slice
 	l32, err := strconv.ParseInt(string(line[:len(line)-1]), 10, 32)
@@ -304,7 +503,7 @@ func (d *Decoder) decodeString(v reflect.Value) error {
 	// read exactly l bytes out and make our string
 	buf := make([]byte, l)
 	_, err = d.readFull(buf)
-	if err != nil || d.raw {
+	if err != nil {
 		return err
 	}
 
@@ -318,71 +517,32 @@ func (d *Decoder) decodeString(v reflect.Value) error {
 		v.SetBytes(buf)
 	case reflect.String:
 		v.SetString(string(buf))
+	case reflect.Float32, reflect.Float64:
+		return d.setFloatString(v, buf)
 	case reflect.Interface:
-		v.Set(reflect.ValueOf(string(buf)))
+		if d.ifaceBytes {
+			v.Set(reflect.ValueOf(buf))
+		} else {
+			v.Set(reflect.ValueOf(string(buf)))
+		}
 	}
 	return nil
 }
 
-func (d *Decoder) decodeList(v reflect.Value) error {
-	if !d.raw {
-		// if we have an interface, just put a []interface{} in it!
-		if v.Kind() == reflect.Interface {
-			var x []interface{}
-			defer func(p reflect.Value) { p.Set(v) }(v)
-			v = reflect.ValueOf(&x).Elem()
-		}
-
-		if v.Kind() != reflect.Array && v.Kind() != reflect.Slice {
-			return fmt.Errorf("Cant store a []interface{} into %s", v.Type())
-		}
-	}
-
-	// read out the l that prefixes the list
-	ch, err := d.readByte()
-	if err != nil {
-		return err
-	}
-	if ch != 'l' {
-		panic("got something other than a list head after a peek")
+func (d *Decoder) decodeList(v reflect.Value, opts tagOptions) error {
+	// if we have an interface, just put a []interface{} in it!
+	if v.Kind() == reflect.Interface {
+		var x []interface{}
+		defer func(p reflect.Value) { p.Set(v) }(v)
+		v = reflect.ValueOf(&x).Elem()
 	}
 
-	// if we're decoding in raw mode,
-	// we only want to read into the buffer,
-	// without actually parsing any values
-	if d.raw {
-		var ch byte
-		for {
-			// peek for the end token and read it out
-			ch, err = d.peekByte()
-			if err != nil {
-				return err
-			}
-			if ch == 'e' {
-				_, err = d.readByte() // consume the end
-				return err
-			}
-
-			// decode the next value
-			err = d.decodeInto(v)
-			if err != nil {
-				return err
-			}
-		}
+	if v.Kind() != reflect.Array && v.Kind() != reflect.Slice || v.Type() == reflectOrderedDictType {
+		return fmt.Errorf("Cant store a []interface{} into %s", v.Type())
 	}
 
-	for i := 0; ; i++ {
-		// peek for the end token and read it out
-		ch, err := d.peekByte()
-		if err != nil {
-			return err
-		}
-		switch ch {
-		case 'e':
-			_, err := d.readByte() // consume the end
-			return err
-		}
-
+	i, elemOpts := 0, elemOptions(opts)
+	return d.decodeElements(func() (bool, error) {
 		// grow it if required
 		if i >= v.Cap() && v.IsValid() {
 			newcap := v.Cap() + v.Cap()/2
@@ -400,65 +560,68 @@ func (d *Decoder) decodeList(v reflect.Value) error {
 		}
 
 		// decode a value into the index
-		if err := d.decodeInto(v.Index(i)); err != nil {
-			return err
-		}
-	}
+		err := d.decodeInto(v.Index(i), elemOpts)
+		i++
+		return true, err
+	})
 }
 
-func (d *Decoder) decodeDict(v reflect.Value) error {
-	// if we have an interface{}, just put a map[string]interface{} in it!
-	if !d.raw && v.Kind() == reflect.Interface {
-		var x map[string]interface{}
-		defer func(p reflect.Value) { p.Set(v) }(v)
-		v = reflect.ValueOf(&x).Elem()
-	}
-
-	// consume the head token
+// decodeElements reads a list, calling elem to decode each element until
+// the list ends or elem returns false or an error.
+func (d *Decoder) decodeElements(elem func() (bool, error)) error {
+	// read out the l that prefixes the list
 	ch, err := d.readByte()
 	if err != nil {
 		return err
 	}
-	if ch != 'd' {
-		panic("got an incorrect token when it was checked already")
+	if ch != 'l' {
+		return fmt.Errorf("Expected a list, got %q", ch)
+	}
+	d.depth++
+	defer func() { d.depth-- }()
+	if err := d.checkLimits(d.depth, 0); err != nil {
+		return err
 	}
 
-	if d.raw {
-		// if we're decoding in raw mode,
-		// we only want to read into the buffer,
-		// without actually parsing any values
-		for {
-			// peek the next value type
-			ch, err := d.peekByte()
-			if err != nil {
-				return err
-			}
-			if ch == 'e' {
-				_, err = d.readByte() // consume the end token
-				return err
-			}
-
-			err = d.decodeString(v)
-			if err != nil {
-				return err
-			}
+	for {
+		// peek for the end token and read it out
+		ch, err := d.peekByte()
+		if err != nil {
+			return err
+		}
+		if ch == 'e' {
+			_, err := d.readByte() // consume the end
+			return err
+		}
 
-			err = d.decodeInto(v)
-			if err != nil {
-				return err
-			}
+		if more, err := elem(); !more || err != nil {
+			return err
 		}
 	}
+}
+
+func (d *Decoder) decodeDict(v reflect.Value, opts tagOptions) error {
+	// if we have an interface{}, just put a map[string]interface{} in it!
+	if v.Kind() == reflect.Interface {
+		var x map[string]interface{}
+		defer func(p reflect.Value) { p.Set(v) }(v)
+		v = reflect.ValueOf(&x).Elem()
+	}
 
 	// check for correct type
 	var (
-		mapElem reflect.Value
-		isMap   bool
-		vals    map[string]reflect.Value
+		mapElem   reflect.Value
+		rest      reflect.Value
+		isMap     bool
+		isOrdered bool
+		vals      map[string]structField
 	)
 
-	switch v.Kind() {
-	case reflect.Map:
+	switch {
+	case v.Type() == reflectOrderedDictType:
+		isOrdered = true
+		v.SetLen(0)
+	case v.Kind() == reflect.Map:
 		t := v.Type()
 		if t.Key() != reflectStringType {
 			return fmt.Errorf("Can't store a map[string]interface{} into %s", v.Type())
@@ -469,21 +632,105 @@ func (d *Decoder) decodeDict(v reflect.Value) error {
 
 		isMap = true
 		mapElem = reflect.New(t.Elem()).Elem()
-	case reflect.Struct:
-		vals = make(map[string]reflect.Value)
+	case v.Kind() == reflect.Struct:
+		vals = make(map[string]structField)
 		setStructValues(vals, v)
+		rest = restField(v)
+		if rest.IsValid() && rest.Type() != reflectRestType {
+			return fmt.Errorf("Can't decode into rest field of type %s", rest.Type())
+		}
 	default:
 		return fmt.Errorf("Can't store a map[string]interface{} into %s", v.Type())
 	}
 
+	elemOpts := elemOptions(opts)
+	return d.decodeEntries(func(key string) (bool, error) {
+		var (
+			subv reflect.Value
+			opts tagOptions
+		)
+
+		// keep every entry of an OrderedDict, in input order
+		if isOrdered {
+			raw, err := d.readRaw()
+			if err != nil {
+				return false, err
+			}
+			ent := OrderedEntry{key, append(RawMessage(nil), raw...)}
+			v.Set(reflect.Append(v, reflect.ValueOf(ent)))
+			return true, nil
+		}
+
+		if isMap {
+			mapElem.Set(reflect.Zero(v.Type().Elem()))
+			subv, opts = mapElem, elemOpts
+		} else {
+			subv, opts = vals[key].v, vals[key].opts
+		}
+
+		if !subv.IsValid() {
+			// keep the values of unknown keys in the rest field
+			if rest.IsValid() {
+				raw, err := d.readRaw()
+				if err != nil {
+					return false, err
+				}
+				if rest.IsNil() {
+					rest.Set(reflect.MakeMap(reflectRestType))
+				}
+				rest.SetMapIndex(reflect.ValueOf(key), reflect.ValueOf(append(RawMessage(nil), raw...)))
+				return true, nil
+			}
+
+			if d.failUnknown && !isMap {
+				return false, fmt.Errorf("unknown field %q in %s", key, v.Type())
+			}
+
+			// if it's invalid, grab but ignore the next value
+			_, err := d.readRaw()
+			return true, err
+		}
+
+		// subv now contains what we load into
+		if err := d.decodeInto(subv, opts); err != nil {
+			return false, err
+		}
+
+		if isMap {
+			v.SetMapIndex(reflect.ValueOf(key), subv)
+		}
+		return true, nil
+	})
+}
+
+// decodeEntries reads a dictionary, checking its keys against the decoder's
+// options and calling entry to decode the value of each key until the
+// dictionary ends or entry returns false or an error.
+func (d *Decoder) decodeEntries(entry func(key string) (bool, error)) error {
+	// consume the head token
+	ch, err := d.readByte()
+	if err != nil {
+		return err
+	}
+	if ch != 'd' {
+		return fmt.Errorf("Expected a dictionary, got %q", ch)
+	}
+	d.depth++
+	defer func() { d.depth-- }()
+	if err := d.checkLimits(d.depth, 0); err != nil {
+		return err
+	}
+
 	var (
 		lastKey string
 		first   bool = true
+		seen    map[string]struct{}
 	)
+	if d.failDuplicate {
+		seen = make(map[string]struct{})
+	}
 
 	for {
-		var subv reflect.Value
-
 		// peek the next value type
 		ch, err := d.peekByte()
 		if err != nil {
@@ -507,39 +754,33 @@ func (d *Decoder) decodeDict(v reflect.Value) error {
 		}
 		lastKey, first = key, false
 
-		if isMap {
-			mapElem.Set(reflect.Zero(v.Type().Elem()))
-			subv = mapElem
-		} else {
-			subv = vals[key]
-		}
-
-		if !subv.IsValid() {
-			// if it's invalid, grab but ignore the next value
-			var x interface{}
-			err := d.decodeInto(reflect.ValueOf(&x).Elem())
-			if err != nil {
-				return err
+		// check for repeated keys
+		if seen != nil {
+			if _, ok := seen[key]; ok {
+				return fmt.Errorf("duplicate dictionary key: %q", key)
 			}
-
-			continue
+			seen[key] = struct{}{}
 		}
 
-		// subv now contains what we load into
-		if err := d.decodeInto(subv); err != nil {
+		if more, err := entry(key); !more || err != nil {
 			return err
 		}
-
-		if isMap {
-			v.SetMapIndex(reflect.ValueOf(key), subv)
-		}
 	}
 }
 
 // indirect walks down v allocating pointers as needed,
 // until it gets to a non-pointer.
-// if it encounters an (Text)Unmarshaler, indirect stops and returns that.
-func (d *Decoder) indirect(v reflect.Value) (Unmarshaler, encoding.TextUnmarshaler, reflect.Value) {
+// if it encounters a type with a Codec, an UnmarshalerFrom or an (Text)Unmarshaler,
+// indirect stops and returns that.
+func (d *Decoder) indirect(v reflect.Value) (UnmarshalerFrom, Unmarshaler, encoding.TextUnmarshaler, reflect.Value) {
+	// a Codec applies to a settable value of any type, as it does when
+	// encoding, and not only to named types reached through their address
+	if v.Kind() != reflect.Ptr && v.CanSet() {
+		if unmarshal := lookupUnmarshal(d.registry, v.Type()); unmarshal != nil {
+			return nil, codecUnmarshaler{unmarshal, v}, nil, reflect.Value{}
+		}
+	}
+
 	// If v is a named type and is addressable,
 	// start with its address, so that if the type has pointer methods,
 	// we find them.
@@ -557,24 +798,57 @@ func (d *Decoder) indirect(v reflect.Value) (Unmarshaler, encoding.TextUnmarshal
 			}
 		}
 
-		if v.Kind() != reflect.Ptr || v.IsNil() {
+		// a pointer to a type with a Codec is allocated if needed
+		if v.Kind() == reflect.Ptr {
+			if unmarshal := lookupUnmarshal(d.registry, v.Type().Elem()); unmarshal != nil {
+				if v.IsNil() {
+					v.Set(reflect.New(v.Type().Elem()))
+				}
+				return nil, codecUnmarshaler{unmarshal, v.Elem()}, nil, reflect.Value{}
+			}
+
+			// time.Time is decoded by decodeTime, not as a TextUnmarshaler
+			if v.Type().Elem() == reflectTimeType {
+				if v.IsNil() {
+					v.Set(reflect.New(reflectTimeType))
+				}
+				return nil, nil, nil, v.Elem()
+			}
+		}
+
+		if v.Kind() != reflect.Ptr {
 			break
 		}
+		if v.IsNil() {
+			if !v.CanSet() {
+				break
+			}
+			v.Set(reflect.New(v.Type().Elem()))
+		}
 
 		vi := v.Interface()
+		if u, ok := vi.(UnmarshalerFrom); ok {
+			return u, nil, nil, reflect.Value{}
+		}
 		if u, ok := vi.(Unmarshaler); ok {
-			return u, nil, reflect.Value{}
+			return nil, u, nil, reflect.Value{}
 		}
 		if u, ok := vi.(encoding.TextUnmarshaler); ok {
-			return nil, u, reflect.Value{}
+			return nil, nil, u, reflect.Value{}
 		}
 
 		v = v.Elem()
 	}
-	return nil, nil, indirect(v, true)
+	return nil, nil, nil, indirect(v, true)
 }
 
-func setStructValues(m map[string]reflect.Value, v reflect.Value) {
+// structField is a struct field that a dictionary key decodes into.
+type structField struct {
+	v    reflect.Value
+	opts tagOptions
+}
+
+func setStructValues(m map[string]structField, v reflect.Value) {
 	t := v.Type()
 	if t.Kind() != reflect.Struct {
 		return
@@ -599,7 +873,11 @@ func setStructValues(m map[string]reflect.Value, v reflect.Value) {
 			continue
 		}
 		v := v.FieldByIndex(f.Index)
-		name, _ := parseTag(f.Tag.Get("bencode"))
+		name, opts := parseTag(f.Tag.Get("bencode"))
+		if opts.Contains("rest") {
+			// it's found by restField instead
+			continue
+		}
 		if name == "" {
 			if f.Anonymous {
 				// it's a struct and its fields have already been added to the map
@@ -608,7 +886,7 @@ func setStructValues(m map[string]reflect.Value, v reflect.Value) {
 			name = f.Name
 		}
 		if isValidTag(name) {
-			m[name] = v
+			m[name] = structField{v, opts}
 		}
 	}
 }
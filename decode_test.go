@@ -2,7 +2,11 @@ package bencode
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"reflect"
 	"sort"
 	"strings"
@@ -205,6 +209,11 @@ func TestDecode(t *testing.T) {
 		{`d3:fooe`, new(interface{}), nil, true, false},
 		{`l3:foo3:bar`, new(interface{}), nil, true, false},
 		{`d-1:`, new(interface{}), nil, true, false},
+		{`i+5e`, new(int), nil, true, false},
+		{`ie`, new(int), nil, true, false},
+		{`i 5e`, new(RawMessage), nil, true, false},
+		{`1+2:foo`, new(string), nil, true, false},
+		{`l0-3:fooe`, new(RawMessage), nil, true, false},
 
 		// embedded structs
 		{`d1:A3:foo1:B3:bare`, new(struct {
@@ -294,6 +303,20 @@ func TestRawDecode(t *testing.T) {
 	}
 }
 
+func TestRawDecodeReadError(t *testing.T) {
+	// an error while reading a string body is kept
+	var x RawMessage
+	r := io.MultiReader(strings.NewReader(`5:ab`), errReader{})
+	if err := NewDecoder(r).Decode(&x); err == nil || err.Error() != "oops" {
+		t.Errorf("got %v", err)
+	}
+
+	// but input that ends inside one is unexpected
+	if err := DecodeString(`5:ab`, &x); err != io.ErrUnexpectedEOF {
+		t.Errorf("got %v", err)
+	}
+}
+
 type myStringType string
 
 // UnmarshalBencode implements Unmarshaler.UnmarshalBencode
@@ -398,3 +421,105 @@ func TestNestedRawDecode(t *testing.T) {
 		}
 	}
 }
+
+// cancelReader returns one byte per read and calls cancel once n bytes have
+// been read.
+type cancelReader struct {
+	r      io.Reader
+	n      int
+	cancel func()
+}
+
+func (c *cancelReader) Read(p []byte) (int, error) {
+	if c.n == 0 {
+		c.cancel()
+	}
+	c.n--
+	return c.r.Read(p[:1])
+}
+
+func TestDecodeContext(t *testing.T) {
+	// decoding with a live context works as usual
+	var x []int
+	d := NewDecoder(strings.NewReader(`li1ei2ee`))
+	if err := d.DecodeContext(context.Background(), &x); err != nil || len(x) != 2 {
+		t.Fatalf("got %v, %v", x, err)
+	}
+
+	// a canceled context stops before reading anything
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := NewDecoder(strings.NewReader(`i1e`)).DecodeContext(ctx, new(int)); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v", err)
+	}
+
+	// cancellation is noticed between values
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	r := &cancelReader{r: strings.NewReader(`li1ei2ei3ee`), n: 4, cancel: cancel}
+	d = NewDecoder(r)
+	err := d.DecodeContext(ctx, &x)
+	if !errors.Is(err, context.Canceled) || !strings.Contains(err.Error(), "offset 4") {
+		t.Errorf("got %v", err)
+	}
+}
+
+// lateReader cancels its context on its last read and returns only once
+// the read deadline has been set, after the whole value has been read.
+type lateReader struct {
+	r        *strings.Reader
+	cancel   func()
+	deadline chan time.Time
+}
+
+func (l *lateReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	if l.r.Len() == 0 && l.cancel != nil {
+		l.cancel()
+		l.cancel = nil
+		<-l.deadline
+	}
+	return n, err
+}
+
+func (l *lateReader) SetReadDeadline(t time.Time) error {
+	l.deadline <- t
+	return nil
+}
+
+func TestDecodeContextLateCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the value is read, but the deadline is set before DecodeContext returns
+	r := &lateReader{r: strings.NewReader(`i1e`), cancel: cancel, deadline: make(chan time.Time)}
+	err := NewDecoder(r).DecodeContext(ctx, new(int))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestDecodeContextDeadline(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	// write part of a value and then stall
+	go server.Write([]byte(`li1e`))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	var x []int
+	done := make(chan error, 1)
+	go func() { done <- NewDecoder(client).DecodeContext(ctx, &x) }()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "offset 4") {
+			t.Errorf("got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("blocked read was not interrupted")
+	}
+}
@@ -0,0 +1,197 @@
+package bencode
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+)
+
+// ChangeKind is the kind of a Change reported by Diff.
+type ChangeKind int
+
+const (
+	Added   ChangeKind = iota + 1 // the value exists only in the second document
+	Removed                       // the value exists only in the first document
+	Changed                       // the value differs between the documents
+)
+
+// String returns the name of the kind.
+func (k ChangeKind) String() string {
+	switch k {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Changed:
+		return "changed"
+	}
+	return "ChangeKind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// A Change is one difference between two bencode documents.
+type Change struct {
+	Kind ChangeKind
+	Path Pointer
+	Old  RawMessage // the value in the first document, nil when Added
+	New  RawMessage // the value in the second document, nil when Removed
+}
+
+// Equal reports whether a and b hold the same bencode value. Values are
+// compared semantically: integers by their numeric value however they are
+// spelled, and dictionaries by their entries regardless of key order, with
+// the last value of a repeated key taking effect as it does when decoding.
+// Invalid documents, including those with lists and dictionaries nested
+// more than 65536 deep, are equal only if they are the same bytes.
+func Equal(a, b []byte) bool {
+	va, errA := parseDocument(a)
+	vb, errB := parseDocument(b)
+	if errA != nil || errB != nil {
+		return bytes.Equal(a, b)
+	}
+	return equalValues(va, vb)
+}
+
+// Diff reports the differences between the bencode documents a and b, using
+// the same comparison as Equal. Dictionaries are compared key by key and
+// lists index by index, so each Change is the smallest differing value,
+// ordered by path. If either document is invalid and they are not the same
+// bytes, the whole documents are reported as Changed.
+func Diff(a, b []byte) []Change {
+	va, errA := parseDocument(a)
+	vb, errB := parseDocument(b)
+	if errA != nil || errB != nil {
+		if bytes.Equal(a, b) {
+			return nil
+		}
+		return []Change{{Kind: Changed, Path: Pointer{}, Old: a, New: b}}
+	}
+	return diffValues(nil, Pointer{}, va, vb)
+}
+
+// parseDocument parses data, which must be exactly one valid value nested
+// no deeper than maxParseDepth, since documents come from peers and clients
+// and are parsed recursively.
+func parseDocument(data []byte) (Value, error) {
+	if err := checkValid(data); err != nil {
+		return Value{}, err
+	}
+	v, _, err := parseValue(data, 0)
+	return v, err
+}
+
+func equalValues(a, b Value) bool {
+	if a.kind != b.kind {
+		return false
+	}
+
+	switch a.kind {
+	case IntKind:
+		return bytes.Equal(canonicalInt(a), canonicalInt(b))
+
+	case StringKind:
+		return bytes.Equal(a.Bytes(), b.Bytes())
+
+	case ListKind:
+		if len(a.list) != len(b.list) {
+			return false
+		}
+		for i := range a.list {
+			if !equalValues(a.list[i], b.list[i]) {
+				return false
+			}
+		}
+		return true
+
+	case DictKind:
+		ka, ma := dictEntries(a)
+		kb, mb := dictEntries(b)
+		if len(ka) != len(kb) {
+			return false
+		}
+		for _, key := range ka {
+			vb, ok := mb[key]
+			if !ok || !equalValues(ma[key], vb) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func diffValues(changes []Change, path Pointer, a, b Value) []Change {
+	switch {
+	case a.kind == ListKind && b.kind == ListKind:
+		for i := 0; i < len(a.list) || i < len(b.list); i++ {
+			sub := appendPath(path, strconv.Itoa(i))
+			switch {
+			case i >= len(b.list):
+				changes = append(changes, Change{Removed, sub, mustRaw(a.list[i]), nil})
+			case i >= len(a.list):
+				changes = append(changes, Change{Added, sub, nil, mustRaw(b.list[i])})
+			default:
+				changes = diffValues(changes, sub, a.list[i], b.list[i])
+			}
+		}
+		return changes
+
+	case a.kind == DictKind && b.kind == DictKind:
+		ka, ma := dictEntries(a)
+		kb, mb := dictEntries(b)
+		keys := append(ka, kb...)
+		sort.Strings(keys)
+		for i, key := range keys {
+			if i > 0 && keys[i-1] == key {
+				continue
+			}
+			va, inA := ma[key]
+			vb, inB := mb[key]
+			sub := appendPath(path, key)
+			switch {
+			case !inB:
+				changes = append(changes, Change{Removed, sub, mustRaw(va), nil})
+			case !inA:
+				changes = append(changes, Change{Added, sub, nil, mustRaw(vb)})
+			default:
+				changes = diffValues(changes, sub, va, vb)
+			}
+		}
+		return changes
+	}
+
+	if !equalValues(a, b) {
+		changes = append(changes, Change{Changed, path, mustRaw(a), mustRaw(b)})
+	}
+	return changes
+}
+
+// dictEntries returns the distinct keys of a dictionary Value and the last
+// value of each.
+func dictEntries(v Value) ([]string, map[string]Value) {
+	keys := make([]string, 0, len(v.entries))
+	m := make(map[string]Value, len(v.entries))
+	for _, ent := range v.entries {
+		if _, ok := m[ent.Key]; !ok {
+			keys = append(keys, ent.Key)
+		}
+		m[ent.Key] = ent.Value
+	}
+	return keys, m
+}
+
+// canonicalInt returns the canonical digits of an integer Value.
+func canonicalInt(v Value) []byte {
+	return appendCanonicalInt(nil, v.raw[1:len(v.raw)-1])
+}
+
+// appendPath returns a copy of path with tok appended, so that sibling
+// paths do not share memory.
+func appendPath(path Pointer, tok string) Pointer {
+	return append(path[:len(path):len(path)], tok)
+}
+
+// mustRaw encodes a Value that was parsed from valid input.
+func mustRaw(v Value) RawMessage {
+	raw, _ := v.MarshalBencode()
+	return raw
+}
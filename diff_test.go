@@ -0,0 +1,96 @@
+package bencode
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEqual(t *testing.T) {
+	type testCase struct {
+		a, b  string
+		equal bool
+	}
+
+	var cases = []testCase{
+		{`i5e`, `i5e`, true},
+		{`i5e`, `i005e`, true},
+		{`i0e`, `i-0e`, true},
+		{`3:foo`, `03:foo`, true},
+		{`d1:ai1e1:bi2ee`, `d1:bi2e1:ai1ee`, true},
+		{`d1:ai1e1:ai2ee`, `d1:ai2ee`, true},
+		{`ld1:ai01eee`, `ld1:ai1eee`, true},
+		{`le`, `le`, true},
+
+		{`i5e`, `i6e`, false},
+		{`i5e`, `1:5`, false},
+		{`li1ei2ee`, `li2ei1ee`, false},
+		{`li1ee`, `li1ei1ee`, false},
+		{`d1:ai1ee`, `d1:bi1ee`, false},
+		{`d1:ai1ee`, `d1:ai1e1:bi1ee`, false},
+		{`de`, `le`, false},
+
+		// invalid documents compare as bytes
+		{`i5`, `i5`, true},
+		{`i5`, `i5e`, false},
+	}
+
+	for i, tt := range cases {
+		if got := Equal([]byte(tt.a), []byte(tt.b)); got != tt.equal {
+			t.Errorf("#%d (%q, %q): Equal = %v", i, tt.a, tt.b, got)
+		}
+		if got := len(Diff([]byte(tt.a), []byte(tt.b))) == 0; got != tt.equal {
+			t.Errorf("#%d (%q, %q): Diff disagrees with Equal", i, tt.a, tt.b)
+		}
+	}
+}
+
+func TestDiffDepth(t *testing.T) {
+	const n = maxParseDepth + 1
+	deep := []byte(strings.Repeat("l", n) + strings.Repeat("e", n))
+	other := []byte(strings.Repeat("l", n) + "i1e" + strings.Repeat("e", n))
+
+	// documents nested too deeply are treated as invalid
+	if !Equal(deep, deep) || Equal(deep, other) {
+		t.Errorf("Equal compared the documents")
+	}
+	if changes := Diff(deep, other); len(changes) != 1 || changes[0].Kind != Changed || len(changes[0].Path) != 0 {
+		t.Errorf("got %v", changes)
+	}
+	if _, err := MergePatch(deep, []byte(`de`)); err == nil {
+		t.Errorf("Expected err is nil")
+	}
+	if _, err := CreateMergePatch([]byte(`de`), deep); err == nil {
+		t.Errorf("Expected err is nil")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	a := `d8:announce3:url4:infod5:filesld6:lengthi1eed6:lengthi2eee4:name3:fooe4:listli1ei2ee5:owneri7ee`
+	b := `d7:comment2:hi8:announce3:url4:infod5:filesld6:lengthi01eed6:lengthi3eee4:name3:bare4:listli1ee5:owner1:7e`
+
+	expect := []Change{
+		{Added, Pointer{"comment"}, nil, RawMessage(`2:hi`)},
+		{Changed, Pointer{"info", "files", "1", "length"}, RawMessage(`i2e`), RawMessage(`i3e`)},
+		{Changed, Pointer{"info", "name"}, RawMessage(`3:foo`), RawMessage(`3:bar`)},
+		{Removed, Pointer{"list", "1"}, RawMessage(`i2e`), nil},
+		{Changed, Pointer{"owner"}, RawMessage(`i7e`), RawMessage(`1:7`)},
+	}
+
+	got := Diff([]byte(a), []byte(b))
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("Diff:\n%v\nwant:\n%v", got, expect)
+	}
+
+	// the reverse diff swaps additions and removals
+	got = Diff([]byte(b), []byte(a))
+	if len(got) != len(expect) || got[0].Kind != Removed || got[3].Kind != Added {
+		t.Errorf("reverse Diff: %v", got)
+	}
+
+	// whole documents are reported when one is invalid
+	got = Diff([]byte(`i1e`), []byte(`i1`))
+	if len(got) != 1 || got[0].Kind != Changed || len(got[0].Path) != 0 {
+		t.Errorf("invalid Diff: %v", got)
+	}
+}
@@ -3,5 +3,40 @@ Package bencode implements encoding and decoding of bencoded objects.
 
 It has a similar API to the encoding/json package and many other
 serialization formats.
+
+# Struct tags
+
+A struct is encoded as a dictionary with an entry for each exported field.
+The "bencode" key of a field's tag gives the key of its entry, followed by
+an optional comma-separated list of options, as in
+
+	Length int64 `bencode:"length,omitempty"`
+
+A field with the tag "-" is left out. The options are:
+
+	omitempty  leave the field out when it holds its zero value
+	compact    write a netip.AddrPort or Node, or a slice of them, as a
+	           string of binary records; see Node
+	unix       write a time.Time or time.Duration as whole seconds
+	unixmilli  write a time.Time or time.Duration as whole milliseconds
+	rfc3339    write a time.Time as an RFC 3339 string, as is the default
+	rest       keep the dictionary entries that no other field holds
+	tuple      write a struct as a list of its field values
+
+The rest option applies to a field of type map[string]RawMessage, and to
+no other type. Decoding stores in it the entry of every key that no field
+of the struct has, even when unknown fields are disallowed, and encoding
+writes its entries among those of the other fields in sorted key order, so
+that a dictionary decoded into the struct is encoded again with the keys
+the struct does not model. An entry whose key is written by another field
+is left out.
+
+The tuple option writes a struct, or each struct of a slice or map, as a
+list of the values of its exported fields in declaration order, with the
+fields of untagged embedded structs in place of them and fields tagged "-"
+left out. The options of each field apply to its value, but omitempty is
+ignored, and a nil pointer or interface field is an error, since leaving it
+out would move the fields after it. Decoding a tuple requires a list with
+exactly one element for each field.
 */
 package bencode
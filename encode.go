@@ -3,10 +3,12 @@ package bencode
 import (
 	"bytes"
 	"encoding"
+	"errors"
 	"fmt"
 	"io"
 	"reflect"
 	"sort"
+	"time"
 )
 
 type sortValues []reflect.Value
@@ -21,25 +23,82 @@ type Marshaler interface {
 	MarshalBencode() ([]byte, error)
 }
 
+// MarshalerTo is the interface implemented by types that can write
+// themselves to an Encoder as a single value, using its BeginList,
+// BeginDict, Key, End and Write methods or Encode, without building the
+// bencode in memory first. It is used in preference to Marshaler.
+type MarshalerTo interface {
+	MarshalBencodeTo(*Encoder) error
+}
+
 // An Encoder writes bencoded objects to an output stream.
 type Encoder struct {
-	w io.Writer
+	w            io.Writer
+	floatPolicy  FloatPolicy
+	nilPolicy    NilPolicy
+	canonicalRaw bool
+	sortOrdered  bool
+	registry     *Registry
+	scratch      []byte
+	stack        []frame // lists and dictionaries opened by BeginList and BeginDict
 }
 
 // NewEncoder returns a new encoder that writes to w.
 func NewEncoder(w io.Writer) *Encoder {
-	return &Encoder{w}
+	return &Encoder{w: w}
+}
+
+// SetFloatPolicy sets how the encoder writes floating-point values.
+// The default is FloatReject.
+func (e *Encoder) SetFloatPolicy(p FloatPolicy) {
+	e.floatPolicy = p
+}
+
+// SetCanonicalizeRaw causes the encoder to rewrite the output of Marshalers
+// and the contents of RawMessages into canonical form before writing them:
+// dictionary keys are sorted and integers and string lengths lose any
+// leading zeros. The default is to write them as they are, after checking
+// that they hold exactly one valid bencode value.
+func (e *Encoder) SetCanonicalizeRaw(canonical bool) {
+	e.canonicalRaw = canonical
+}
+
+// SetSortOrderedDicts causes the encoder to write the entries of an
+// OrderedDict sorted by key, keeping entries with equal keys in their stored
+// order. The default is to write them in the order they are stored.
+func (e *Encoder) SetSortOrderedDicts(sort bool) {
+	e.sortOrdered = sort
 }
 
 // Encode writes the bencoded data of val to its output stream.
-// If an encountered value implements the Marshaler interface,
+// If an encountered value implements the MarshalerTo interface,
+// its MarshalBencodeTo method is called to write the value.
+// Otherwise, if it implements the Marshaler interface,
 // its MarshalBencode method is called to produce the bencode output for this value.
+// The output of MarshalBencode, like the contents of a RawMessage, must be
+// exactly one valid bencode value or Encode returns an error.
 // If no MarshalBencode method is present but the value implements encoding.TextMarshaler instead,
 // its MarshalText method is called, which encodes the result as a bencode string.
 // See the documentation for Decode about the conversion of Go values to
 // bencoded data.
+// Inside a list or dictionary opened with BeginList or BeginDict, Encode
+// writes the next element or dictionary value. A nil value is left out of a
+// list, but is an error as a dictionary value or as the value written by a
+// MarshalerTo.
 func (e *Encoder) Encode(val interface{}) error {
-	return encodeValue(e.w, reflect.ValueOf(val))
+	// nothing would be written where a value is needed
+	if e.mustWrite() && isNilChain(reflect.ValueOf(val)) {
+		return errors.New("Encode called with a nil value where a value must be written")
+	}
+	if err := e.beforeValue(); err != nil {
+		return err
+	}
+	// an untyped nil writes nothing, as a nil pointer does
+	v := reflect.ValueOf(val)
+	if !v.IsValid() {
+		return nil
+	}
+	return e.encodeValue(v, "")
 }
 
 // EncodeString returns the bencoded data of val as a string.
@@ -67,8 +126,25 @@ func isNilValue(v reflect.Value) bool {
 		v.IsNil()
 }
 
-func encodeValue(w io.Writer, val reflect.Value) error {
-	marshaler, textMarshaler, v := indirectEncodeValue(val)
+// encodeValue writes val, using the options of the struct field tag that
+// val comes from, if any.
+func (e *Encoder) encodeValue(val reflect.Value, opts tagOptions) error {
+	if opts.Contains("compact") {
+		return e.encodeCompact(val, opts)
+	}
+
+	// write the key of a Union into the value held by its interface
+	if u, iv := unionTarget(e.registry, val); u != nil && !iv.IsNil() {
+		return e.encodeUnion(u, iv)
+	}
+
+	w := e.w
+	marshalerTo, marshaler, textMarshaler, v := indirectEncodeValue(val, e.registry)
+
+	// let a MarshalerTo write itself
+	if marshalerTo != nil {
+		return e.marshalTo(marshalerTo)
+	}
 
 	// marshal a type using the Marshaler type
 	// if it implements that interface.
@@ -78,8 +154,13 @@ func encodeValue(w io.Writer, val reflect.Value) error {
 			return err
 		}
 
-		_, err = w.Write(bytes)
-		return err
+		if err := e.writeRaw(bytes); err != nil {
+			if cm, ok := marshaler.(codecMarshaler); ok {
+				return fmt.Errorf("invalid output from Codec for type %s: %w", cm.v.Type(), err)
+			}
+			return fmt.Errorf("invalid output from MarshalBencode for type %T: %w", marshaler, err)
+		}
+		return nil
 	}
 
 	// marshal a type using the TextMarshaler type
@@ -97,13 +178,38 @@ func encodeValue(w io.Writer, val reflect.Value) error {
 	// if indirection returns us an invalid value that means there was a nil
 	// pointer in the path somewhere.
 	if !v.IsValid() {
+		if e.nilPolicy == NilReject {
+			return errors.New("Can't encode a nil value")
+		}
 		return nil
 	}
 
 	// send in a raw message if we have that type
 	if rm, ok := v.Interface().(RawMessage); ok {
-		_, err := io.Copy(w, bytes.NewReader(rm))
-		return err
+		if err := e.writeRaw(rm); err != nil {
+			return fmt.Errorf("invalid RawMessage: %w", err)
+		}
+		return nil
+	}
+
+	// copy the contents of a ReaderString from its reader
+	if v.Type() == reflectReaderStringType {
+		return e.encodeReaderString(v.Interface().(ReaderString))
+	}
+
+	// write times and durations in the units asked for by the tag options
+	switch v.Type() {
+	case reflectTimeType:
+		return e.encodeTime(v.Interface().(time.Time), opts)
+	case reflectDurationType:
+		if ok, err := e.encodeDuration(time.Duration(v.Int()), opts); ok {
+			return err
+		}
+	}
+
+	// write the entries of an OrderedDict in their stored order
+	if v.Type() == reflectOrderedDictType {
+		return e.encodeOrderedDict(v.Interface().(OrderedDict))
 	}
 
 	switch v.Kind() {
@@ -123,6 +229,9 @@ func encodeValue(w io.Writer, val reflect.Value) error {
 		_, err := fmt.Fprintf(w, "i%de", i)
 		return err
 
+	case reflect.Float32, reflect.Float64:
+		return e.encodeFloat(v)
+
 	case reflect.String:
 		_, err := fmt.Fprintf(w, "%d:%s", len(v.String()), v.String())
 		return err
@@ -143,8 +252,9 @@ func encodeValue(w io.Writer, val reflect.Value) error {
 			return err
 		}
 
+		elemOpts := elemOptions(opts)
 		for i := 0; i < v.Len(); i++ {
-			if err := encodeValue(w, v.Index(i)); err != nil {
+			if err := e.encodeValue(v.Index(i), elemOpts); err != nil {
 				return err
 			}
 		}
@@ -166,10 +276,10 @@ func encodeValue(w io.Writer, val reflect.Value) error {
 			if isNilValue(mval) {
 				continue
 			}
-			if err := encodeValue(w, keys[i]); err != nil {
+			if err := e.encodeValue(keys[i], ""); err != nil {
 				return err
 			}
-			if err := encodeValue(w, mval); err != nil {
+			if err := e.encodeValue(mval, elemOptions(opts)); err != nil {
 				return err
 			}
 		}
@@ -177,6 +287,11 @@ func encodeValue(w io.Writer, val reflect.Value) error {
 		return err
 
 	case reflect.Struct:
+		// write the fields of a struct in order as a list
+		if opts.Contains("tuple") {
+			return e.encodeTuple(v)
+		}
+
 		if _, err := fmt.Fprint(w, "d"); err != nil {
 			return err
 		}
@@ -190,17 +305,18 @@ func encodeValue(w io.Writer, val reflect.Value) error {
 
 		// sort the dictionary by keys
 		sort.Sort(dict)
+		dict = mergeRest(dict)
 
 		// encode the dictionary in order
 		for _, def := range dict {
 			// encode the key
-			err := encodeValue(w, reflect.ValueOf(def.key))
+			err := e.encodeValue(reflect.ValueOf(def.key), "")
 			if err != nil {
 				return err
 			}
 
 			// encode the value
-			err = encodeValue(w, def.value)
+			err = e.encodeValue(def.value, def.opts)
 			if err != nil {
 				return err
 			}
@@ -213,10 +329,28 @@ func encodeValue(w io.Writer, val reflect.Value) error {
 	return fmt.Errorf("Can't encode type: %s", v.Type())
 }
 
+// writeRaw writes a bencode value produced outside the encoder,
+// returning an error instead if data is not exactly one valid value.
+func (e *Encoder) writeRaw(data []byte) error {
+	if e.canonicalRaw {
+		var err error
+		e.scratch, err = Canonicalize(e.scratch[:0], data)
+		if err != nil {
+			return err
+		}
+		data = e.scratch
+	} else if err := checkValid(data); err != nil {
+		return err
+	}
+	_, err := e.w.Write(data)
+	return err
+}
+
 // indirectEncodeValue walks down v allocating pointers as needed,
 // until it gets to a non-pointer.
-// if it encounters an (Text)Marshaler, indirect stops and returns that.
-func indirectEncodeValue(v reflect.Value) (Marshaler, encoding.TextMarshaler, reflect.Value) {
+// if it encounters a type with a Codec in r, a MarshalerTo or an (Text)Marshaler,
+// indirect stops and returns that.
+func indirectEncodeValue(v reflect.Value, r *Registry) (MarshalerTo, Marshaler, encoding.TextMarshaler, reflect.Value) {
 	// If v is a named type and is addressable,
 	// start with its address, so that if the type has pointer methods,
 	// we find them.
@@ -228,12 +362,33 @@ func indirectEncodeValue(v reflect.Value) (Marshaler, encoding.TextMarshaler, re
 			break
 		}
 
+		// look through interfaces for types with a Codec
+		if v.Kind() == reflect.Interface && !v.IsNil() {
+			v = v.Elem()
+			continue
+		}
+		t := v.Type()
+		if v.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if marshal := lookupMarshal(r, t); marshal != nil {
+			return nil, codecMarshaler{marshal, reflect.Indirect(v)}, nil, reflect.Value{}
+		}
+
+		// time.Time is encoded by encodeTime, not as a TextMarshaler
+		if t == reflectTimeType {
+			return nil, nil, nil, reflect.Indirect(v)
+		}
+
 		vi := v.Interface()
+		if m, ok := vi.(MarshalerTo); ok {
+			return m, nil, nil, reflect.Value{}
+		}
 		if m, ok := vi.(Marshaler); ok {
-			return m, nil, reflect.Value{}
+			return nil, m, nil, reflect.Value{}
 		}
 		if m, ok := vi.(encoding.TextMarshaler); ok {
-			return nil, m, reflect.Value{}
+			return nil, nil, m, reflect.Value{}
 		}
 
 		if v.Kind() != reflect.Ptr {
@@ -242,12 +397,13 @@ func indirectEncodeValue(v reflect.Value) (Marshaler, encoding.TextMarshaler, re
 
 		v = v.Elem()
 	}
-	return nil, nil, indirect(v, false)
+	return nil, nil, nil, indirect(v, false)
 }
 
 type definition struct {
 	key   string
 	value reflect.Value
+	opts  tagOptions
 }
 
 type dictionary []definition
@@ -290,13 +446,15 @@ func readStruct(dict dictionary, v reflect.Value) (dictionary, error) {
 		//   key in struct field's tag value is the key name, followed
 		//   by an optional comma and options.
 		tagValue := key.Tag.Get("bencode")
+		var options tagOptions
 		if tagValue != "" {
 			// Keys with '-' are omit from output
 			if tagValue == "-" {
 				continue
 			}
 
-			name, options := parseTag(tagValue)
+			var name string
+			name, options = parseTag(tagValue)
 			// Keys with 'omitempty' are omitted if the field is empty
 			if options.Contains("omitempty") && isEmptyValue(fieldValue) {
 				continue
@@ -308,14 +466,20 @@ func readStruct(dict dictionary, v reflect.Value) (dictionary, error) {
 			}
 		}
 
-		if key.Anonymous && key.Type.Kind() == reflect.Struct && tagValue == "" {
+		if options.Contains("rest") {
+			var err error
+			dict, err = appendRest(dict, fieldValue)
+			if err != nil {
+				return nil, err
+			}
+		} else if key.Anonymous && key.Type.Kind() == reflect.Struct && tagValue == "" {
 			var err error
 			dict, err = readStruct(dict, fieldValue)
 			if err != nil {
 				return nil, err
 			}
 		} else {
-			dict = append(dict, definition{rkey, fieldValue})
+			dict = append(dict, definition{rkey, fieldValue, options})
 		}
 	}
 	return dict, nil
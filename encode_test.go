@@ -1,6 +1,7 @@
 package bencode
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"testing"
@@ -352,3 +353,63 @@ func TestEncodeOmit(t *testing.T) {
 		}
 	}
 }
+
+type rawMarshalType string
+
+// MarshalBencode implements Marshaler.MarshalBencode
+func (rmt rawMarshalType) MarshalBencode() ([]byte, error) {
+	return []byte(rmt), nil
+}
+
+func TestEncodeRawValidation(t *testing.T) {
+	type encodeTestCase struct {
+		in        interface{}
+		canonical bool
+		out       string
+		err       bool
+	}
+
+	var encodeCases = []encodeTestCase{
+		// marshalers must produce exactly one value
+		{rawMarshalType(`i5e`), false, `i5e`, false},
+		{rawMarshalType(``), false, ``, true},
+		{rawMarshalType(`i5ei6e`), false, ``, true},
+		{rawMarshalType(`3:foojunk`), false, ``, true},
+		{rawMarshalType(`l3:foo`), false, ``, true},
+		{map[string]interface{}{"a": rawMarshalType(`i1`)}, false, ``, true},
+
+		// and so must raw messages
+		{RawMessage(`d1:ai1ee`), false, `d1:ai1ee`, false},
+		{RawMessage(nil), false, ``, true},
+		{RawMessage(`d1:ai1ee1:x`), false, ``, true},
+		{[]RawMessage{RawMessage(`i1e`), RawMessage(`i2`)}, false, ``, true},
+
+		// non-canonical output is written as is unless asked otherwise
+		{rawMarshalType(`d1:bi01e1:ai2ee`), false, `d1:bi01e1:ai2ee`, false},
+		{rawMarshalType(`d1:bi01e1:ai2ee`), true, `d1:ai2e1:bi1ee`, false},
+		{RawMessage(`li-0e02:abe`), true, `li0e2:abe`, false},
+		{struct {
+			A RawMessage
+			B rawMarshalType
+		}{RawMessage(`d1:zi0e1:yi0ee`), rawMarshalType(`i007e`)}, true, `d1:Ad1:yi0e1:zi0ee1:Bi7ee`, false},
+		{RawMessage(`i5ei6e`), true, ``, true},
+	}
+
+	for i, tt := range encodeCases {
+		buf := new(bytes.Buffer)
+		enc := NewEncoder(buf)
+		enc.SetCanonicalizeRaw(tt.canonical)
+		err := enc.Encode(tt.in)
+		if !tt.err && err != nil {
+			t.Errorf("#%d: Unexpected err: %v", i, err)
+			continue
+		}
+		if tt.err && err == nil {
+			t.Errorf("#%d: Expected err is nil", i)
+			continue
+		}
+		if !tt.err && tt.out != buf.String() {
+			t.Errorf("#%d: Val: %q != %q", i, buf.String(), tt.out)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package bencode
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// FloatPolicy controls how floating-point values are written by an Encoder
+// and read by a Decoder. Bencode has no floating-point type, so every policy
+// other than FloatReject maps floats onto integers or strings.
+type FloatPolicy int
+
+const (
+	// FloatReject refuses to encode floating-point values and to decode
+	// into floating-point fields. It is the default.
+	FloatReject FloatPolicy = iota
+
+	// FloatString encodes floating-point values as decimal strings using the
+	// fewest digits that parse back to the same value.
+	FloatString
+
+	// FloatInteger encodes floating-point values as integers, failing for
+	// values that are not exactly integral or do not fit in an int64.
+	FloatInteger
+)
+
+// String returns the name of the policy.
+func (p FloatPolicy) String() string {
+	switch p {
+	case FloatReject:
+		return "FloatReject"
+	case FloatString:
+		return "FloatString"
+	case FloatInteger:
+		return "FloatInteger"
+	}
+	return fmt.Sprintf("FloatPolicy(%d)", int(p))
+}
+
+func (e *Encoder) encodeFloat(v reflect.Value) error {
+	f, bits := v.Float(), v.Type().Bits()
+
+	switch e.floatPolicy {
+	case FloatString:
+		s := strconv.FormatFloat(f, 'g', -1, bits)
+		_, err := fmt.Fprintf(e.w, "%d:%s", len(s), s)
+		return err
+
+	case FloatInteger:
+		// the upper bound is exclusive: 1<<63 is representable as a float
+		// but not as an int64.
+		if f != math.Trunc(f) || f < -(1<<63) || f >= 1<<63 {
+			return fmt.Errorf("Can't encode %s %v as an integer", v.Type(), f)
+		}
+		_, err := fmt.Fprintf(e.w, "i%de", int64(f))
+		return err
+	}
+
+	return fmt.Errorf("Can't encode type: %s (floating-point values require a FloatPolicy)", v.Type())
+}
+
+// setFloatInt stores the bencode integer digits into the float value v.
+func (d *Decoder) setFloatInt(v reflect.Value, digits string) error {
+	if d.floatPolicy == FloatReject {
+		return fmt.Errorf("Cannot store int64 into %s (floating-point values require a FloatPolicy)", v.Type())
+	}
+	n, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return err
+	}
+	v.SetFloat(float64(n))
+	return nil
+}
+
+// setFloatString parses the bencode string buf as a decimal number and stores
+// it into the float value v.
+func (d *Decoder) setFloatString(v reflect.Value, buf []byte) error {
+	if d.floatPolicy == FloatReject {
+		return fmt.Errorf("Cannot store string into %s (floating-point values require a FloatPolicy)", v.Type())
+	}
+	f, err := strconv.ParseFloat(string(buf), v.Type().Bits())
+	if err != nil {
+		return err
+	}
+	v.SetFloat(f)
+	return nil
+}
@@ -0,0 +1,136 @@
+package bencode
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEncodeFloat(t *testing.T) {
+	type encodeTestCase struct {
+		in     interface{}
+		policy FloatPolicy
+		out    string
+		err    bool
+	}
+
+	var encodeCases = []encodeTestCase{
+		// rejected by default
+		{1.5, FloatReject, ``, true},
+		{float32(1), FloatReject, ``, true},
+		{struct{ F float64 }{2}, FloatReject, ``, true},
+
+		// decimal strings
+		{1.5, FloatString, `3:1.5`, false},
+		{0.1, FloatString, `3:0.1`, false},
+		{float32(0.1), FloatString, `3:0.1`, false},
+		{-2.0, FloatString, `2:-2`, false},
+		{1e21, FloatString, `5:1e+21`, false},
+		{math.Inf(1), FloatString, `4:+Inf`, false},
+
+		// integers
+		{2.0, FloatInteger, `i2e`, false},
+		{-1e15, FloatInteger, `i-1000000000000000e`, false},
+		{float32(7), FloatInteger, `i7e`, false},
+		{1.5, FloatInteger, ``, true},
+		{math.NaN(), FloatInteger, ``, true},
+		{math.Inf(-1), FloatInteger, ``, true},
+		{float64(1 << 63), FloatInteger, ``, true},
+		{float64(-(1 << 63)), FloatInteger, `i-9223372036854775808e`, false},
+
+		// inside containers
+		{struct {
+			F float64 `bencode:"f"`
+			G float64 `bencode:"g,omitempty"`
+		}{F: 0.25}, FloatString, `d1:f4:0.25e`, false},
+		{[]float64{1, 2}, FloatInteger, `li1ei2ee`, false},
+	}
+
+	for i, tt := range encodeCases {
+		buf := new(bytes.Buffer)
+		enc := NewEncoder(buf)
+		enc.SetFloatPolicy(tt.policy)
+		err := enc.Encode(tt.in)
+		if !tt.err && err != nil {
+			t.Errorf("#%d: Unexpected err: %v", i, err)
+			continue
+		}
+		if tt.err && err == nil {
+			t.Errorf("#%d: Expected err is nil", i)
+			continue
+		}
+		if !tt.err && tt.out != buf.String() {
+			t.Errorf("#%d: Val: %q != %q", i, buf.String(), tt.out)
+		}
+	}
+}
+
+func TestDecodeFloat(t *testing.T) {
+	type decodeTestCase struct {
+		in     string
+		val    interface{}
+		policy FloatPolicy
+		expect interface{}
+		err    bool
+	}
+
+	var decodeCases = []decodeTestCase{
+		{`i5e`, new(float64), FloatReject, nil, true},
+		{`3:1.5`, new(float64), FloatReject, nil, true},
+
+		{`i5e`, new(float64), FloatString, float64(5), false},
+		{`i-5e`, new(float32), FloatInteger, float32(-5), false},
+		{`3:1.5`, new(float64), FloatString, float64(1.5), false},
+		{`3:0.1`, new(float32), FloatString, float32(0.1), false},
+		{`5:1e+21`, new(float64), FloatInteger, float64(1e21), false},
+		{`3:abc`, new(float64), FloatString, nil, true},
+		{`lee`, new(float64), FloatString, nil, true},
+
+		{`d1:f4:0.25e`, new(struct {
+			F float64 `bencode:"f"`
+		}), FloatString, struct {
+			F float64 `bencode:"f"`
+		}{0.25}, false},
+	}
+
+	for i, tt := range decodeCases {
+		dec := NewDecoder(strings.NewReader(tt.in))
+		dec.SetFloatPolicy(tt.policy)
+		err := dec.Decode(tt.val)
+		if !tt.err && err != nil {
+			t.Errorf("#%d (%v): Unexpected err: %v", i, tt.in, err)
+			continue
+		}
+		if tt.err && err == nil {
+			t.Errorf("#%d (%v): Expected err is nil", i, tt.in)
+			continue
+		}
+		v := reflect.ValueOf(tt.val).Elem().Interface()
+		if !tt.err && !reflect.DeepEqual(v, tt.expect) {
+			t.Errorf("#%d (%v): Val: %#v != %#v", i, tt.in, v, tt.expect)
+		}
+	}
+}
+
+func TestFloatRoundTrip(t *testing.T) {
+	for _, f := range []float64{0, 1, -1, 0.1, math.Pi, math.MaxFloat64, math.SmallestNonzeroFloat64} {
+		buf := new(bytes.Buffer)
+		enc := NewEncoder(buf)
+		enc.SetFloatPolicy(FloatString)
+		if err := enc.Encode(f); err != nil {
+			t.Fatal(err)
+		}
+
+		var got float64
+		dec := NewDecoder(buf)
+		dec.SetFloatPolicy(FloatString)
+		if err := dec.Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got != f {
+			t.Errorf("%v round tripped to %v", f, got)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package bencode
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// Get returns the raw bencode of the value found by following path from the
+// value in data. Each element of path is either a string (or []byte), which
+// selects the value of that key in a dictionary, or an int, which selects an
+// element of a list. When a key appears more than once the last value is
+// used, as it would be by Decode.
+//
+// Get scans data without decoding it, skipping over values that are not on
+// the path, and does not allocate. The result refers to memory in data.
+func Get(data []byte, path ...interface{}) (RawMessage, error) {
+	var s scanner
+	start, end, err := find(&s, data, path)
+	if err != nil {
+		return nil, err
+	}
+	return RawMessage(data[start:end:end]), nil
+}
+
+// GetInto finds the value at path in data, as Get does, and decodes it into
+// the value pointed to by val.
+func GetInto(data []byte, val interface{}, path ...interface{}) error {
+	raw, err := Get(data, path...)
+	if err != nil {
+		return err
+	}
+	return DecodeBytes(raw, val)
+}
+
+// A PathError reports that a path given to Get does not exist in the data.
+type PathError struct {
+	Path  []interface{} // the path up to and including the missing element
+	Cause string
+}
+
+func (e *PathError) Error() string {
+	return fmt.Sprintf("bencode path %v: %s", e.Path, e.Cause)
+}
+
+// find returns the offsets of the value at path in data. The whole of data
+// must be a single valid value.
+func find(s *scanner, data []byte, path []interface{}) (start, end int, err error) {
+	end, err = scanValue(s, data, 0)
+	if err != nil {
+		return 0, 0, err
+	}
+	if end != len(data) {
+		return 0, 0, &SyntaxError{"trailing data after value", int64(end)}
+	}
+
+	for i, elem := range path {
+		switch elem := elem.(type) {
+		case string:
+			start, end, err = findKey(s, data, start, elem)
+		case []byte:
+			start, end, err = findKey(s, data, start, string(elem))
+		case int:
+			start, end, err = findIndex(s, data, start, elem)
+		default:
+			return 0, 0, fmt.Errorf("invalid path element %v of type %T", elem, elem)
+		}
+		if err != nil {
+			return 0, 0, &PathError{append([]interface{}(nil), path[:i+1]...), err.Error()}
+		}
+	}
+	return start, end, nil
+}
+
+// findKey returns the offsets of the last value of key in the dictionary
+// that starts at data[start].
+func findKey(s *scanner, data []byte, start int, key string) (int, int, error) {
+	if data[start] != 'd' {
+		return 0, 0, errors.New("not a dictionary")
+	}
+
+	found := false
+	var vstart, vend int
+	for off := start + 1; data[off] != 'e'; {
+		k, koff := readKey(data, off)
+		next, _ := scanValue(s, data, koff)
+		if string(k) == key {
+			found, vstart, vend = true, koff, next
+		}
+		off = next
+	}
+	if !found {
+		return 0, 0, errors.New("key not found")
+	}
+	return vstart, vend, nil
+}
+
+// findIndex returns the offsets of the i'th element of the list that starts
+// at data[start].
+func findIndex(s *scanner, data []byte, start, i int) (int, int, error) {
+	if data[start] != 'l' {
+		return 0, 0, errors.New("not a list")
+	}
+
+	for off, n := start+1, 0; data[off] != 'e'; n++ {
+		next, _ := scanValue(s, data, off)
+		if n == i {
+			return off, next, nil
+		}
+		off = next
+	}
+	return 0, 0, errors.New("index out of range")
+}
+
+// readKey reads the valid bencode string at data[off:] without allocating,
+// returning its contents and the offset just past it.
+func readKey(data []byte, off int) ([]byte, int) {
+	colon := off + bytes.IndexByte(data[off:], ':')
+	var n int
+	for _, c := range data[off:colon] {
+		n = n*10 + int(c-'0')
+	}
+	return data[colon+1 : colon+1+n], colon + 1 + n
+}
@@ -0,0 +1,93 @@
+package bencode
+
+import (
+	"reflect"
+	"testing"
+)
+
+const getTorrent = `d8:announce3:url4:infod5:filesld6:lengthi1e4:pathl1:a1:beed6:lengthi2e4:pathl1:ceee4:name4:test12:piece lengthi16384ee4:listli1ei2ei3eee`
+
+func TestGet(t *testing.T) {
+	type testCase struct {
+		in     string
+		path   []interface{}
+		expect string
+		err    bool
+	}
+
+	var cases = []testCase{
+		{getTorrent, nil, getTorrent, false},
+		{getTorrent, []interface{}{"announce"}, `3:url`, false},
+		{getTorrent, []interface{}{"info", "name"}, `4:test`, false},
+		{getTorrent, []interface{}{[]byte("info"), "piece length"}, `i16384e`, false},
+		{getTorrent, []interface{}{"info", "files", 1}, `d6:lengthi2e4:pathl1:cee`, false},
+		{getTorrent, []interface{}{"info", "files", 0, "path", 1}, `1:b`, false},
+		{getTorrent, []interface{}{"list", 2}, `i3e`, false},
+
+		// the last of repeated keys wins
+		{`d1:ai1e1:ai2ee`, []interface{}{"a"}, `i2e`, false},
+
+		// missing paths
+		{getTorrent, []interface{}{"missing"}, ``, true},
+		{getTorrent, []interface{}{"list", 3}, ``, true},
+		{getTorrent, []interface{}{"list", -1}, ``, true},
+		{getTorrent, []interface{}{"announce", "x"}, ``, true},
+		{getTorrent, []interface{}{0}, ``, true},
+		{getTorrent, []interface{}{1.5}, ``, true},
+
+		// invalid data
+		{`d1:ai1e`, []interface{}{"a"}, ``, true},
+		{`d1:ai1ee1:x`, []interface{}{"a"}, ``, true},
+	}
+
+	for i, tt := range cases {
+		raw, err := Get([]byte(tt.in), tt.path...)
+		if !tt.err && err != nil {
+			t.Errorf("#%d (%v): Unexpected err: %v", i, tt.path, err)
+			continue
+		}
+		if tt.err && err == nil {
+			t.Errorf("#%d (%v): Expected err is nil", i, tt.path)
+			continue
+		}
+		if string(raw) != tt.expect {
+			t.Errorf("#%d: Val: %q != %q", i, raw, tt.expect)
+		}
+	}
+
+	if _, err := Get([]byte(getTorrent), "info", "nope"); err != nil {
+		if perr, ok := err.(*PathError); !ok || !reflect.DeepEqual(perr.Path, []interface{}{"info", "nope"}) {
+			t.Errorf("unexpected error %#v", err)
+		}
+	}
+}
+
+func TestGetInto(t *testing.T) {
+	var name string
+	if err := GetInto([]byte(getTorrent), &name, "info", "name"); err != nil || name != "test" {
+		t.Errorf("got %q, %v", name, err)
+	}
+
+	var path []string
+	if err := GetInto([]byte(getTorrent), &path, "info", "files", 0, "path"); err != nil ||
+		!reflect.DeepEqual(path, []string{"a", "b"}) {
+		t.Errorf("got %q, %v", path, err)
+	}
+
+	var n int
+	if err := GetInto([]byte(getTorrent), &n, "info", "name"); err == nil {
+		t.Errorf("Expected err is nil")
+	}
+}
+
+func TestGetAllocs(t *testing.T) {
+	data := []byte(getTorrent)
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, err := Get(data, "info", "files", 1, "path", 0); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("Get allocated %v times", allocs)
+	}
+}
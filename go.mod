@@ -1 +1,3 @@
 module github.com/zeebo/bencode
+
+go 1.23
@@ -0,0 +1,71 @@
+package bencode
+
+import (
+	"io"
+	"iter"
+	"reflect"
+)
+
+// Iter returns an iterator over the elements of the bencoded list read from
+// r, decoding each one into a T as it is reached, so that a long list need
+// not be held in memory at once. Decoding errors, including input that is
+// not a list, are yielded once with the zero T and end the iteration.
+// See the documentation for Decode about how elements are stored into T.
+func Iter[T any](r io.Reader) iter.Seq2[T, error] {
+	return Elements[T](NewDecoder(r))
+}
+
+// Elements returns an iterator over the elements of the next list read from
+// d, like Iter. If the caller stops early, d is left inside the list and
+// should not be used to decode more values.
+func Elements[T any](d *Decoder) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		err := d.decodeElements(func() (bool, error) {
+			var val T
+			if err := d.decodeInto(reflect.ValueOf(&val).Elem(), ""); err != nil {
+				return false, err
+			}
+			return yield(val, nil), nil
+		})
+		if err != nil {
+			var zero T
+			yield(zero, err)
+		}
+	}
+}
+
+// Entries returns an iterator over the keys and values of the bencoded
+// dictionary read from r, yielding each entry as it is reached, and a
+// function that returns the error that ended the iteration, or nil if the
+// dictionary ended or the caller stopped. The Decoder options that fail on
+// unordered or duplicate keys are not set; use the Entries method of a
+// Decoder to set them.
+func Entries(r io.Reader) (iter.Seq2[string, RawMessage], func() error) {
+	return NewDecoder(r).Entries()
+}
+
+// Entries returns an iterator over the keys and values of the next
+// dictionary read from d, like the Entries function, enforcing the options
+// set by SetFailOnUnorderedKeys and SetFailOnDuplicateKeys. If the caller
+// stops early, d is left inside the dictionary and should not be used to
+// decode more values.
+func (d *Decoder) Entries() (iter.Seq2[string, RawMessage], func() error) {
+	return EntriesOf[RawMessage](d)
+}
+
+// EntriesOf is like the Entries method of d, but decodes each value into a
+// V. See the documentation for Decode about how values are stored into V.
+// Each call has its own error, which is checked after the loop.
+func EntriesOf[V any](d *Decoder) (iter.Seq2[string, V], func() error) {
+	var err error
+	seq := func(yield func(string, V) bool) {
+		err = d.decodeEntries(func(key string) (bool, error) {
+			var val V
+			if err := d.decodeInto(reflect.ValueOf(&val).Elem(), ""); err != nil {
+				return false, err
+			}
+			return yield(key, val), nil
+		})
+	}
+	return seq, func() error { return err }
+}
@@ -0,0 +1,208 @@
+package bencode
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIter(t *testing.T) {
+	type testCase struct {
+		in     string
+		expect []int
+		err    bool
+	}
+
+	var cases = []testCase{
+		{`le`, nil, false},
+		{`li1ei2ei3ee`, []int{1, 2, 3}, false},
+		{`li1ei2e`, []int{1, 2}, true},
+		{`li1e3:fooi3ee`, []int{1}, true},
+		{`i1e`, nil, true},
+		{``, nil, true},
+	}
+
+	for i, tt := range cases {
+		var got []int
+		var err error
+		for v, verr := range Iter[int](strings.NewReader(tt.in)) {
+			if verr != nil {
+				err = verr
+				break
+			}
+			got = append(got, v)
+		}
+		if !tt.err && err != nil {
+			t.Errorf("#%d: Unexpected err: %v", i, err)
+			continue
+		}
+		if tt.err && err == nil {
+			t.Errorf("#%d: Expected err is nil", i)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.expect) {
+			t.Errorf("#%d: Val: %v != %v", i, got, tt.expect)
+		}
+	}
+}
+
+func TestIterStructs(t *testing.T) {
+	type peer struct {
+		IP   string `bencode:"ip"`
+		Port int    `bencode:"port"`
+	}
+
+	in := `ld2:ip4:host4:porti1eed2:ip4:peer4:porti2eee`
+	var got []peer
+	for p, err := range Iter[peer](strings.NewReader(in)) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, p)
+	}
+	expect := []peer{{"host", 1}, {"peer", 2}}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestElementsBreak(t *testing.T) {
+	// stopping early does not read past the element that was yielded
+	in := `li1ei2ei3ee`
+	d := NewDecoder(bytes.NewReader([]byte(in)))
+	calls := 0
+	for v, err := range Elements[int](d) {
+		calls++
+		if err != nil || v != 1 {
+			t.Fatalf("got %v, %v", v, err)
+		}
+		break
+	}
+	if calls != 1 || d.BytesParsed() != len(`li1e`) {
+		t.Errorf("calls %d, parsed %d", calls, d.BytesParsed())
+	}
+
+	// a decoder can iterate over several lists in a stream
+	d = NewDecoder(strings.NewReader(`li1eeli2ei3ee`))
+	var got []int
+	for range 2 {
+		for v, err := range Elements[int](d) {
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, v)
+		}
+	}
+	if !reflect.DeepEqual(got, []int{1, 2, 3}) {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestEntries(t *testing.T) {
+	type entry struct {
+		Key   string
+		Value string
+	}
+
+	type testCase struct {
+		in                 string
+		unordered, dupKeys bool
+		expect             []entry
+		err                bool
+	}
+
+	var cases = []testCase{
+		{`de`, false, false, nil, false},
+		{`d1:ai1e1:bli2eee`, false, false, []entry{{"a", `i1e`}, {"b", `li2ee`}}, false},
+		{`d1:bi1e1:ai2ee`, false, false, []entry{{"b", `i1e`}, {"a", `i2e`}}, false},
+		{`d1:bi1e1:ai2ee`, true, false, []entry{{"b", `i1e`}}, true},
+		{`d1:ai1e1:ai2ee`, true, false, []entry{{"a", `i1e`}, {"a", `i2e`}}, false},
+		{`d1:ai1e1:ai2ee`, false, true, []entry{{"a", `i1e`}}, true},
+		{`d1:ai1e1:bi2ee`, true, true, []entry{{"a", `i1e`}, {"b", `i2e`}}, false},
+		{`d1:ai1e1:bi2`, false, false, []entry{{"a", `i1e`}}, true},
+		{`li1ee`, false, false, nil, true},
+	}
+
+	for i, tt := range cases {
+		d := NewDecoder(strings.NewReader(tt.in))
+		d.SetFailOnUnorderedKeys(tt.unordered)
+		d.SetFailOnDuplicateKeys(tt.dupKeys)
+
+		var got []entry
+		entries, errf := d.Entries()
+		for k, v := range entries {
+			got = append(got, entry{k, string(v)})
+		}
+		if err := errf(); !tt.err && err != nil {
+			t.Errorf("#%d: Unexpected err: %v", i, err)
+			continue
+		} else if tt.err && err == nil {
+			t.Errorf("#%d: Expected err is nil", i)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.expect) {
+			t.Errorf("#%d: Val: %v != %v", i, got, tt.expect)
+		}
+	}
+}
+
+func TestEntriesOf(t *testing.T) {
+	var keys []string
+	var vals []int
+	entries, errf := Entries(strings.NewReader(`d1:bi1e1:ai2e1:ci3ee`))
+	for k := range entries {
+		keys = append(keys, k)
+		if len(keys) == 2 {
+			break
+		}
+	}
+	if errf() != nil || !reflect.DeepEqual(keys, []string{"b", "a"}) {
+		t.Errorf("got %v, %v", keys, errf())
+	}
+
+	// the reader form reports errors too
+	entries, errf = Entries(strings.NewReader(`d1:ai1e1:b`))
+	for range entries {
+	}
+	if errf() == nil {
+		t.Errorf("Expected err is nil")
+	}
+
+	keys = nil
+	d := NewDecoder(strings.NewReader(`d1:ai1e1:bi2ee`))
+	ints, errf := EntriesOf[int](d)
+	for k, v := range ints {
+		keys = append(keys, k)
+		vals = append(vals, v)
+	}
+	if errf() != nil || !reflect.DeepEqual(keys, []string{"a", "b"}) || !reflect.DeepEqual(vals, []int{1, 2}) {
+		t.Errorf("got %v, %v, %v", keys, vals, errf())
+	}
+
+	// each iteration of a decoder keeps its own error
+	d = NewDecoder(strings.NewReader(`d1:ai1eed1:b3:fooe`))
+	first, errFirst := EntriesOf[int](d)
+	for range first {
+	}
+	second, errSecond := EntriesOf[int](d)
+	for range second {
+	}
+	if errFirst() != nil || errSecond() == nil {
+		t.Errorf("got %v, %v", errFirst(), errSecond())
+	}
+}
+
+func TestDecodeDuplicateKeys(t *testing.T) {
+	var m map[string]int
+	if err := DecodeString(`d1:ai1e1:ai2ee`, &m); err != nil || m["a"] != 2 {
+		t.Errorf("got %v, %v", m, err)
+	}
+
+	d := NewDecoder(strings.NewReader(`d1:ai1e1:bd1:xi1e1:xi2eee`))
+	d.SetFailOnDuplicateKeys(true)
+	var x interface{}
+	if err := d.Decode(&x); err == nil {
+		t.Errorf("Expected err is nil")
+	}
+}
@@ -0,0 +1,142 @@
+package bencode
+
+import (
+	"errors"
+	"sort"
+)
+
+// Tombstone is the value that deletes a key when it appears in a merge
+// patch. Bencode has no null, which plays this part in JSON merge patches
+// (RFC 7386), so a dictionary holding only the key "$tombstone" is used
+// instead. Any encoding that is Equal to it is treated the same way.
+const Tombstone = "d10:$tombstonei1ee"
+
+var tombstoneValue, _ = parseDocument([]byte(Tombstone))
+
+// MergePatch applies patch to doc following the rules of RFC 7386: if patch
+// is a dictionary, each of its keys is merged recursively into doc, which is
+// treated as an empty dictionary if it is not one, and keys whose patch value
+// is the Tombstone are deleted. Any other patch replaces doc entirely.
+// The result is in canonical form.
+func MergePatch(doc, patch []byte) ([]byte, error) {
+	dv, err := parseDocument(doc)
+	if err != nil {
+		return nil, err
+	}
+	pv, err := parseDocument(patch)
+	if err != nil {
+		return nil, err
+	}
+	if equalValues(pv, tombstoneValue) {
+		return nil, errors.New("merge patch deletes the whole document")
+	}
+	return Canonicalize(nil, mustRaw(mergeValues(dv, pv)))
+}
+
+// CreateMergePatch returns a merge patch that turns original into modified
+// when applied by MergePatch. Keys missing from modified are deleted with
+// the Tombstone, and values that differ are replaced, recursing into
+// dictionaries present in both documents. The patch is in canonical form.
+//
+// Because the Tombstone stands for deletion, a modified document that holds
+// it as a dictionary value cannot be produced by a merge patch, and
+// CreateMergePatch returns an error for it.
+func CreateMergePatch(original, modified []byte) ([]byte, error) {
+	ov, err := parseDocument(original)
+	if err != nil {
+		return nil, err
+	}
+	mv, err := parseDocument(modified)
+	if err != nil {
+		return nil, err
+	}
+	if containsTombstone(mv) {
+		return nil, errors.New("modified document contains the tombstone value")
+	}
+	return Canonicalize(nil, mustRaw(createPatch(ov, mv)))
+}
+
+func mergeValues(target, patch Value) Value {
+	if patch.kind != DictKind {
+		return patch
+	}
+	if target.kind != DictKind {
+		target = NewDict()
+	}
+
+	keys, values := dictEntries(target)
+	pkeys, pvalues := dictEntries(patch)
+	for _, key := range pkeys {
+		pv := pvalues[key]
+		tv, ok := values[key]
+		switch {
+		case equalValues(pv, tombstoneValue):
+			delete(values, key)
+		case ok:
+			values[key] = mergeValues(tv, pv)
+		default:
+			keys = append(keys, key)
+			values[key] = mergeValues(Value{}, pv)
+		}
+	}
+	return sortedDict(keys, values)
+}
+
+func createPatch(original, modified Value) Value {
+	if original.kind != DictKind || modified.kind != DictKind {
+		return modified
+	}
+
+	okeys, ovalues := dictEntries(original)
+	mkeys, mvalues := dictEntries(modified)
+	patch := make(map[string]Value)
+	var keys []string
+
+	for _, key := range okeys {
+		if _, ok := mvalues[key]; !ok {
+			keys = append(keys, key)
+			patch[key] = tombstoneValue
+		}
+	}
+	for _, key := range mkeys {
+		mv := mvalues[key]
+		ov, ok := ovalues[key]
+		switch {
+		case !ok:
+			keys = append(keys, key)
+			patch[key] = mv
+		case !equalValues(ov, mv):
+			keys = append(keys, key)
+			patch[key] = createPatch(ov, mv)
+		}
+	}
+	return sortedDict(keys, patch)
+}
+
+// containsTombstone reports whether v is the Tombstone or holds it as the
+// value of a key in nested dictionaries. Lists are not searched because
+// merge patches replace them without looking inside.
+func containsTombstone(v Value) bool {
+	if equalValues(v, tombstoneValue) {
+		return true
+	}
+	for _, ent := range v.entries {
+		if containsTombstone(ent.Value) {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedDict returns a dictionary Value of the keys that are still present
+// in values, in sorted order.
+func sortedDict(keys []string, values map[string]Value) Value {
+	sort.Strings(keys)
+	entries := make([]DictEntry, 0, len(keys))
+	for _, key := range keys {
+		if v, ok := values[key]; ok {
+			entries = append(entries, DictEntry{key, v})
+		}
+	}
+	return Value{kind: DictKind, entries: entries}
+}
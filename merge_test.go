@@ -0,0 +1,99 @@
+package bencode
+
+import "testing"
+
+func TestMergePatch(t *testing.T) {
+	type testCase struct {
+		doc, patch string
+		expect     string
+		err        bool
+	}
+
+	var cases = []testCase{
+		// dictionaries merge recursively
+		{`d1:ai1ee`, `d1:bi2ee`, `d1:ai1e1:bi2ee`, false},
+		{`d1:ai1ee`, `d1:ai2ee`, `d1:ai2ee`, false},
+		{`d1:ad1:xi1e1:yi2eee`, `d1:ad1:yi3eee`, `d1:ad1:xi1e1:yi3eee`, false},
+
+		// tombstones delete keys, even missing ones
+		{`d1:ai1e1:bi2ee`, `d1:a` + Tombstone + `e`, `d1:bi2ee`, false},
+		{`d1:ai1ee`, `d1:z` + Tombstone + `e`, `d1:ai1ee`, false},
+		{`d1:ad1:xi1eee`, `d1:ad1:x` + Tombstone + `ee`, `d1:adee`, false},
+		{`d1:ai1ee`, `d1:ad1:x` + Tombstone + `1:yi1eee`, `d1:ad1:yi1eee`, false},
+
+		// anything but a dictionary replaces the target
+		{`d1:ali1ei2eee`, `d1:ali3eee`, `d1:ali3eee`, false},
+		{`d1:ai1ee`, `i5e`, `i5e`, false},
+		{`i5e`, `d1:ai1ee`, `d1:ai1ee`, false},
+		{`d1:ai1ee`, `d1:ad1:bi1eee`, `d1:ad1:bi1eee`, false},
+
+		// the result is canonical
+		{`d1:bi01e1:ai1ee`, `de`, `d1:ai1e1:bi1ee`, false},
+		{`de`, `d1:bi1e1:ai02ee`, `d1:ai2e1:bi1ee`, false},
+
+		{`d1:ai1ee`, Tombstone, ``, true},
+		{`d1:ai1e`, `de`, ``, true},
+		{`de`, `d1:a`, ``, true},
+	}
+
+	for i, tt := range cases {
+		out, err := MergePatch([]byte(tt.doc), []byte(tt.patch))
+		if !tt.err && err != nil {
+			t.Errorf("#%d: Unexpected err: %v", i, err)
+			continue
+		}
+		if tt.err && err == nil {
+			t.Errorf("#%d: Expected err is nil", i)
+			continue
+		}
+		if string(out) != tt.expect {
+			t.Errorf("#%d: Val: %q != %q", i, out, tt.expect)
+		}
+	}
+}
+
+func TestCreateMergePatch(t *testing.T) {
+	type testCase struct {
+		original, modified string
+		expect             string
+		err                bool
+	}
+
+	var cases = []testCase{
+		{`d1:ai1ee`, `d1:ai1ee`, `de`, false},
+		{`d1:ai1ee`, `d1:ai2ee`, `d1:ai2ee`, false},
+		{`d1:ai1e1:bi2ee`, `d1:ai1ee`, `d1:b` + Tombstone + `e`, false},
+		{`d1:ai1ee`, `d1:ai1e1:bi2ee`, `d1:bi2ee`, false},
+		{`d1:ad1:xi1e1:yi2eee`, `d1:ad1:xi1e1:yi3eee`, `d1:ad1:yi3eee`, false},
+		{`d1:ali1ei2eee`, `d1:ali1eee`, `d1:ali1eee`, false},
+		{`d1:ai1ee`, `i5e`, `i5e`, false},
+		{`d1:bi1e1:ai01ee`, `d1:ai1e1:bi1ee`, `de`, false},
+
+		{`de`, `d1:a` + Tombstone + `e`, ``, true},
+		{`de`, Tombstone, ``, true},
+	}
+
+	for i, tt := range cases {
+		patch, err := CreateMergePatch([]byte(tt.original), []byte(tt.modified))
+		if !tt.err && err != nil {
+			t.Errorf("#%d: Unexpected err: %v", i, err)
+			continue
+		}
+		if tt.err && err == nil {
+			t.Errorf("#%d: Expected err is nil", i)
+			continue
+		}
+		if tt.err {
+			continue
+		}
+		if string(patch) != tt.expect {
+			t.Errorf("#%d: Val: %q != %q", i, patch, tt.expect)
+		}
+
+		// applying the patch produces the modified document
+		out, err := MergePatch([]byte(tt.original), patch)
+		if err != nil || !Equal(out, []byte(tt.modified)) {
+			t.Errorf("#%d: MergePatch gave %q, %v; want %q", i, out, err, tt.modified)
+		}
+	}
+}
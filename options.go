@@ -0,0 +1,127 @@
+package bencode
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// NilPolicy controls how an Encoder handles nil pointers and interfaces
+// that are neither struct fields nor map values, which are always omitted.
+type NilPolicy int
+
+const (
+	// NilOmit writes nothing for a nil value, so that a nil list element
+	// is left out of the list. This is the default.
+	NilOmit NilPolicy = iota
+
+	// NilReject makes encoding a nil value fail.
+	NilReject
+)
+
+// String returns the name of the policy.
+func (p NilPolicy) String() string {
+	switch p {
+	case NilOmit:
+		return "NilOmit"
+	case NilReject:
+		return "NilReject"
+	}
+	return "NilPolicy(" + strconv.Itoa(int(p)) + ")"
+}
+
+// Options is a set of encoding and decoding settings that can be configured
+// once and shared. Options is used by value, so a copy held by a service
+// cannot be changed by others, and its methods are safe to call from
+// multiple goroutines. The zero value gives the same behavior as the
+// package-level functions.
+type Options struct {
+	// FailOnUnorderedKeys and FailOnDuplicateKeys make decoding fail on
+	// dictionaries with keys out of order or repeated, as the Decoder
+	// methods of the same names do.
+	FailOnUnorderedKeys bool
+	FailOnDuplicateKeys bool
+
+	// DisallowUnknownFields makes decoding into a struct fail when a
+	// dictionary has a key that matches none of its fields. By default
+	// such keys are skipped.
+	DisallowUnknownFields bool
+
+	// MaxDepth limits how deeply lists and dictionaries may be nested when
+	// decoding, and MaxStringLength limits the length of decoded strings.
+	// Zero means no limit.
+	MaxDepth        int
+	MaxStringLength int64
+
+	// InterfaceBytes makes strings decoded into an empty interface be
+	// stored as []byte instead of string. InterfaceValues makes every value
+	// decoded into an empty interface be stored as a Value instead, keeping
+	// its exact encoding.
+	InterfaceBytes  bool
+	InterfaceValues bool
+
+	// FloatPolicy sets how floating-point values are encoded and decoded.
+	FloatPolicy FloatPolicy
+
+	// NilPolicy sets how nil values are encoded.
+	NilPolicy NilPolicy
+
+	// CanonicalizeRaw and SortOrderedDicts configure the Encoder as the
+	// methods of the same names do.
+	CanonicalizeRaw  bool
+	SortOrderedDicts bool
+
+	// Registry holds Codecs that take precedence over the global ones
+	// added by Register. It may be nil.
+	Registry *Registry
+}
+
+// NewEncoder returns a new encoder that writes to w using the options.
+func (o Options) NewEncoder(w io.Writer) *Encoder {
+	e := NewEncoder(w)
+	e.floatPolicy = o.FloatPolicy
+	e.nilPolicy = o.NilPolicy
+	e.canonicalRaw = o.CanonicalizeRaw
+	e.sortOrdered = o.SortOrderedDicts
+	e.registry = o.Registry
+	return e
+}
+
+// NewDecoder returns a new decoder that reads from r using the options.
+func (o Options) NewDecoder(r io.Reader) *Decoder {
+	d := NewDecoder(r)
+	d.failUnordered = o.FailOnUnorderedKeys
+	d.failDuplicate = o.FailOnDuplicateKeys
+	d.failUnknown = o.DisallowUnknownFields
+	d.maxDepth = o.MaxDepth
+	d.maxStringLen = o.MaxStringLength
+	d.ifaceBytes = o.InterfaceBytes
+	d.ifaceValues = o.InterfaceValues
+	d.floatPolicy = o.FloatPolicy
+	d.registry = o.Registry
+	return d
+}
+
+// Marshal returns the bencoded data of val using the options.
+func (o Options) Marshal(val interface{}) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := o.NewEncoder(buf).Encode(val); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decodes the bencoded value in data into the value pointed to by
+// val using the options. Unlike DecodeBytes, it fails if data holds anything
+// after the value.
+func (o Options) Unmarshal(data []byte, val interface{}) error {
+	d := o.NewDecoder(bytes.NewReader(data))
+	if err := d.Decode(val); err != nil {
+		return err
+	}
+	if d.n != len(data) {
+		return fmt.Errorf("trailing data after value at offset %d", d.n)
+	}
+	return nil
+}
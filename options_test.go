@@ -0,0 +1,148 @@
+package bencode
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestOptionsUnmarshal(t *testing.T) {
+	type named struct {
+		A int `bencode:"a"`
+	}
+
+	type testCase struct {
+		opts   Options
+		in     string
+		val    interface{}
+		expect interface{}
+		err    bool
+	}
+
+	var cases = []testCase{
+		{Options{}, `i5e`, new(int), 5, false},
+		{Options{}, `i5ei6e`, new(int), 5, true},
+
+		{Options{FailOnUnorderedKeys: true}, `d1:bi1e1:ai2ee`, new(map[string]int), map[string]int(nil), true},
+		{Options{FailOnDuplicateKeys: true}, `d1:ai1e1:ai2ee`, new(map[string]int), map[string]int(nil), true},
+
+		{Options{}, `d1:ai1e1:bi2ee`, new(named), named{1}, false},
+		{Options{DisallowUnknownFields: true}, `d1:ai1ee`, new(named), named{1}, false},
+		{Options{DisallowUnknownFields: true}, `d1:ai1e1:bi2ee`, new(named), named{1}, true},
+		{Options{DisallowUnknownFields: true}, `d1:ai1e1:bi2ee`, new(map[string]int), map[string]int{"a": 1, "b": 2}, false},
+
+		{Options{MaxDepth: 2}, `lli1eee`, new([][]int), [][]int{{1}}, false},
+		{Options{MaxDepth: 2}, `llli1eeee`, new(interface{}), nil, true},
+		{Options{MaxDepth: 2}, `d1:ali1eee`, new(map[string][]int), map[string][]int{"a": {1}}, false},
+		{Options{MaxDepth: 1}, `d1:ali1eee`, new(RawMessage), RawMessage(nil), true},
+		{Options{MaxDepth: 1}, `d1:ali1ee1:bi1ee`, new(struct{ B int }), struct{ B int }{}, true},
+
+		{Options{MaxStringLength: 3}, `3:foo`, new(string), "foo", false},
+		{Options{MaxStringLength: 3}, `4:food`, new(string), "", true},
+		{Options{MaxStringLength: 3}, `l4:foode`, new(RawMessage), RawMessage(nil), true},
+		{Options{MaxStringLength: 3}, `d1:b4:foode`, new(named), named{}, true},
+
+		{Options{InterfaceBytes: true}, `l3:fooi1ee`, new(interface{}), []interface{}{[]byte("foo"), int64(1)}, false},
+		{Options{InterfaceValues: true}, `d1:bi01e1:ai2ee`, new(interface{}), NewDict(DictEntry{"b", NewInt(1)}, DictEntry{"a", NewInt(2)}), false},
+		{Options{InterfaceValues: true}, `d1:xli1eee`, new(map[string]interface{}), map[string]interface{}{"x": NewList(NewInt(1))}, false},
+
+		{Options{FloatPolicy: FloatString}, `3:1.5`, new(float64), 1.5, false},
+		{Options{}, `3:1.5`, new(float64), 0.0, true},
+	}
+
+	for i, tt := range cases {
+		err := tt.opts.Unmarshal([]byte(tt.in), tt.val)
+		if !tt.err && err != nil {
+			t.Errorf("#%d: Unexpected err: %v", i, err)
+			continue
+		}
+		if tt.err && err == nil {
+			t.Errorf("#%d: Expected err is nil", i)
+			continue
+		}
+		if tt.err {
+			continue
+		}
+		got := reflect.ValueOf(tt.val).Elem().Interface()
+		if !sameValue(got, tt.expect) {
+			t.Errorf("#%d: Val: %#v != %#v", i, got, tt.expect)
+		}
+	}
+}
+
+// sameValue compares values that hold a Value, whose parsed and constructed
+// forms differ in memory, by their encodings.
+func sameValue(a, b interface{}) bool {
+	if reflect.DeepEqual(a, b) {
+		return true
+	}
+	ea, errA := EncodeBytes(a)
+	eb, errB := EncodeBytes(b)
+	return errA == nil && errB == nil && Equal(ea, eb) &&
+		reflect.TypeOf(a) == reflect.TypeOf(b)
+}
+
+func TestOptionsMarshal(t *testing.T) {
+	var nilPtr *int
+
+	type testCase struct {
+		opts   Options
+		val    interface{}
+		expect string
+		err    bool
+	}
+
+	var cases = []testCase{
+		{Options{}, []interface{}{nilPtr, 1}, `li1ee`, false},
+		{Options{NilPolicy: NilReject}, []interface{}{nilPtr, 1}, ``, true},
+		{Options{NilPolicy: NilReject}, []interface{}{nil, 1}, ``, true},
+		{Options{NilPolicy: NilReject}, struct{ A *int }{}, `de`, false},
+		{Options{NilPolicy: NilReject}, map[string]*int{"a": nil}, `de`, false},
+
+		{Options{}, 1.5, ``, true},
+		{Options{FloatPolicy: FloatString}, 1.5, `3:1.5`, false},
+
+		{Options{}, RawMessage(`d1:bi1e1:ai1ee`), `d1:bi1e1:ai1ee`, false},
+		{Options{CanonicalizeRaw: true}, RawMessage(`d1:bi1e1:ai1ee`), `d1:ai1e1:bi1ee`, false},
+
+		{Options{}, OrderedDict{{"b", RawMessage(`i1e`)}, {"a", RawMessage(`i2e`)}}, `d1:bi1e1:ai2ee`, false},
+		{Options{SortOrderedDicts: true}, OrderedDict{{"b", RawMessage(`i1e`)}, {"a", RawMessage(`i2e`)}}, `d1:ai2e1:bi1ee`, false},
+	}
+
+	for i, tt := range cases {
+		got, err := tt.opts.Marshal(tt.val)
+		if !tt.err && err != nil {
+			t.Errorf("#%d: Unexpected err: %v", i, err)
+			continue
+		}
+		if tt.err && err == nil {
+			t.Errorf("#%d: Expected err is nil", i)
+			continue
+		}
+		if string(got) != tt.expect {
+			t.Errorf("#%d: Val: %q != %q", i, got, tt.expect)
+		}
+	}
+}
+
+func TestOptionsConcurrent(t *testing.T) {
+	opts := Options{FailOnDuplicateKeys: true, MaxDepth: 4}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			data, err := opts.Marshal(map[string][]int{"a": {i}})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			var x map[string][]int
+			if err := opts.Unmarshal(data, &x); err != nil || x["a"][0] != i {
+				t.Errorf("got %v, %v", x, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,69 @@
+package bencode
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+var reflectOrderedDictType = reflect.TypeOf(OrderedDict(nil))
+
+// OrderedDict is a dictionary that keeps its entries in the order they
+// appear in the input, including repeated keys, and encodes them in the
+// order they are stored. Together with the RawMessage values this lets a
+// dictionary from a non-canonical encoder be written back with its entries
+// unchanged, except that a key length written with leading zeros, as in
+// d01:ai1ee, is written without them; decode into a Value to keep those
+// too. Use SetSortOrderedDicts on the Encoder to sort the entries by key
+// instead.
+type OrderedDict []OrderedEntry
+
+// OrderedEntry is a single key and value of an OrderedDict.
+type OrderedEntry struct {
+	Key   string
+	Value RawMessage
+}
+
+// Lookup returns the value of the last entry with the given key and reports
+// whether there was one.
+func (d OrderedDict) Lookup(key string) (RawMessage, bool) {
+	for i := len(d) - 1; i >= 0; i-- {
+		if d[i].Key == key {
+			return d[i].Value, true
+		}
+	}
+	return nil, false
+}
+
+// Set replaces the value of the last entry with the given key, or appends
+// a new entry if there is none.
+func (d *OrderedDict) Set(key string, value RawMessage) {
+	for i := len(*d) - 1; i >= 0; i-- {
+		if (*d)[i].Key == key {
+			(*d)[i].Value = value
+			return
+		}
+	}
+	*d = append(*d, OrderedEntry{key, value})
+}
+
+func (e *Encoder) encodeOrderedDict(d OrderedDict) error {
+	if e.sortOrdered && !sort.SliceIsSorted(d, func(i, j int) bool { return d[i].Key < d[j].Key }) {
+		d = append(OrderedDict(nil), d...)
+		sort.SliceStable(d, func(i, j int) bool { return d[i].Key < d[j].Key })
+	}
+
+	if _, err := fmt.Fprint(e.w, "d"); err != nil {
+		return err
+	}
+	for _, ent := range d {
+		if _, err := fmt.Fprintf(e.w, "%d:%s", len(ent.Key), ent.Key); err != nil {
+			return err
+		}
+		if err := e.writeRaw(ent.Value); err != nil {
+			return fmt.Errorf("invalid RawMessage for key %q: %w", ent.Key, err)
+		}
+	}
+	_, err := fmt.Fprint(e.w, "e")
+	return err
+}
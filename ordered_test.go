@@ -0,0 +1,146 @@
+package bencode
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestOrderedDictRoundTrip(t *testing.T) {
+	var cases = []string{
+		`de`,
+		`d1:ai1ee`,
+		`d1:bi1e1:ai2ee`,
+		`d1:ai1e1:ai2ee`,
+		`d1:bi01e1:a03:fooe`,
+		`d1:zd1:bi1e1:ai2ee1:ali2ei1eee`,
+	}
+
+	for i, in := range cases {
+		var d OrderedDict
+		if err := DecodeString(in, &d); err != nil {
+			t.Errorf("#%d (%q): Unexpected err: %v", i, in, err)
+			continue
+		}
+		out, err := EncodeString(d)
+		if err != nil {
+			t.Errorf("#%d (%q): Unexpected err: %v", i, in, err)
+			continue
+		}
+		if out != in {
+			t.Errorf("#%d: Val: %q != %q", i, out, in)
+		}
+	}
+
+	// only the values are kept as written, not the key lengths
+	var d OrderedDict
+	if err := DecodeString(`d01:ai01ee`, &d); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := EncodeString(d); err != nil || out != `d1:ai01ee` {
+		t.Errorf("got %q, %v", out, err)
+	}
+
+	for i, in := range []string{`le`, `i1e`, `d1:ai1e`, `d1:a`} {
+		var d OrderedDict
+		if err := DecodeString(in, &d); err == nil {
+			t.Errorf("#%d (%q): Expected err is nil", i, in)
+		}
+	}
+}
+
+func TestOrderedDictDecode(t *testing.T) {
+	var x struct {
+		Info OrderedDict `bencode:"info"`
+		Name string      `bencode:"name"`
+	}
+	in := `d4:infod1:bi1e1:al1:xe1:bi3ee4:name3:fooe`
+	if err := DecodeString(in, &x); err != nil {
+		t.Fatal(err)
+	}
+
+	expect := OrderedDict{
+		{"b", RawMessage(`i1e`)},
+		{"a", RawMessage(`l1:xe`)},
+		{"b", RawMessage(`i3e`)},
+	}
+	if !reflect.DeepEqual(x.Info, expect) || x.Name != "foo" {
+		t.Errorf("got %q, %q", x.Info, x.Name)
+	}
+
+	if v, ok := x.Info.Lookup("b"); !ok || string(v) != `i3e` {
+		t.Errorf("Lookup(b) = %q, %v", v, ok)
+	}
+	if _, ok := x.Info.Lookup("c"); ok {
+		t.Errorf("Lookup(c) found a value")
+	}
+
+	// decoding replaces the previous entries
+	if err := DecodeString(`d1:ci1ee`, &x.Info); err != nil {
+		t.Fatal(err)
+	}
+	if len(x.Info) != 1 || x.Info[0].Key != "c" {
+		t.Errorf("got %q", x.Info)
+	}
+
+	// an interface holding a pointer to an OrderedDict is filled in
+	var d OrderedDict
+	var iface interface{} = &d
+	if err := DecodeString(`d1:bi1e1:ai2ee`, &iface); err != nil {
+		t.Fatal(err)
+	}
+	if len(d) != 2 || d[0].Key != "b" {
+		t.Errorf("got %q", d)
+	}
+
+	dec := NewDecoder(bytes.NewReader([]byte(`d1:bi1e1:ai2ee`)))
+	dec.SetFailOnUnorderedKeys(true)
+	if err := dec.Decode(&d); err == nil {
+		t.Errorf("Expected err is nil")
+	}
+}
+
+func TestOrderedDictEncode(t *testing.T) {
+	var d OrderedDict
+	d.Set("b", RawMessage(`i01e`))
+	d.Set("a", RawMessage(`3:foo`))
+	d.Set("b", RawMessage(`i2e`))
+	d = append(d, OrderedEntry{"a", RawMessage(`i0e`)})
+
+	type testCase struct {
+		sort, canonical bool
+		expect          string
+	}
+
+	var cases = []testCase{
+		{false, false, `d1:bi2e1:a3:foo1:ai0ee`},
+		{true, false, `d1:a3:foo1:ai0e1:bi2ee`},
+		{false, true, `d1:bi2e1:a3:foo1:ai0ee`},
+	}
+
+	for i, tt := range cases {
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf)
+		enc.SetSortOrderedDicts(tt.sort)
+		enc.SetCanonicalizeRaw(tt.canonical)
+		if err := enc.Encode(d); err != nil {
+			t.Errorf("#%d: Unexpected err: %v", i, err)
+			continue
+		}
+		if buf.String() != tt.expect {
+			t.Errorf("#%d: Val: %q != %q", i, buf.String(), tt.expect)
+		}
+	}
+
+	// sorting does not modify the dictionary
+	if d[0].Key != "b" {
+		t.Errorf("dictionary was reordered: %q", d)
+	}
+
+	if _, err := EncodeString(OrderedDict{{"a", nil}}); err == nil {
+		t.Errorf("Expected err is nil")
+	}
+	if _, err := EncodeString(OrderedDict{{"a", RawMessage(`i1`)}}); err == nil {
+		t.Errorf("Expected err is nil")
+	}
+}
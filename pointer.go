@@ -0,0 +1,270 @@
+package bencode
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A Pointer addresses a value inside a bencode document, in the manner of a
+// JSON Pointer (RFC 6901). Each element is a reference token: a dictionary
+// key, or the decimal index of a list element. The empty Pointer addresses
+// the whole document.
+type Pointer []string
+
+// ParsePointer parses the string form of a pointer, such as
+// "/info/files/3/path". Each reference token is preceded by a '/', and within
+// a token "~1" stands for '/' and "~0" for '~'.
+func ParsePointer(s string) (Pointer, error) {
+	if s == "" {
+		return Pointer{}, nil
+	}
+	if s[0] != '/' {
+		return nil, fmt.Errorf("invalid pointer %q: must be empty or start with '/'", s)
+	}
+
+	tokens := strings.Split(s[1:], "/")
+	for i, tok := range tokens {
+		if !strings.Contains(tok, "~") {
+			continue
+		}
+		var b strings.Builder
+		for j := 0; j < len(tok); j++ {
+			if tok[j] != '~' {
+				b.WriteByte(tok[j])
+				continue
+			}
+			if j+1 == len(tok) || (tok[j+1] != '0' && tok[j+1] != '1') {
+				return nil, fmt.Errorf("invalid pointer %q: bad escape in %q", s, tok)
+			}
+			if tok[j+1] == '0' {
+				b.WriteByte('~')
+			} else {
+				b.WriteByte('/')
+			}
+			j++
+		}
+		tokens[i] = b.String()
+	}
+	return Pointer(tokens), nil
+}
+
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// String returns the string form of p, escaping '~' and '/' in its tokens.
+func (p Pointer) String() string {
+	var b strings.Builder
+	for _, tok := range p {
+		b.WriteByte('/')
+		b.WriteString(pointerEscaper.Replace(tok))
+	}
+	return b.String()
+}
+
+// Get returns the raw bencode of the value p addresses in doc. The result
+// refers to memory in doc.
+func (p Pointer) Get(doc []byte) (RawMessage, error) {
+	var s scanner
+	start, end, err := p.find(&s, doc, len(p))
+	if err != nil {
+		return nil, err
+	}
+	return RawMessage(doc[start:end:end]), nil
+}
+
+// Set returns a copy of doc in which the value p addresses is replaced by
+// value, which must be a single valid bencode value. If the last token of p
+// names a key that is missing from its dictionary, the entry is inserted
+// before the first greater key, so that sorted dictionaries stay sorted. If
+// it is "-" or the length of its list, value is appended to the list.
+// All other bytes of doc are preserved exactly.
+func (p Pointer) Set(doc, value []byte) ([]byte, error) {
+	if err := checkValid(value); err != nil {
+		return nil, err
+	}
+
+	var s scanner
+	if len(p) == 0 {
+		if err := checkValid(doc); err != nil {
+			return nil, err
+		}
+		return append([]byte(nil), value...), nil
+	}
+
+	pstart, pend, err := p.find(&s, doc, len(p)-1)
+	if err != nil {
+		return nil, err
+	}
+	tok := p[len(p)-1]
+
+	// start and end are the span of doc replaced by value,
+	// and key is written before value when inserting a dictionary entry.
+	var start, end int
+	var key []byte
+
+	switch doc[pstart] {
+	case 'd':
+		start, end, err = findKey(&s, doc, pstart, tok)
+		if err != nil {
+			start = dictInsertOffset(&s, doc, pstart, tok)
+			end, err = start, nil
+			key = appendString(nil, []byte(tok))
+		}
+
+	case 'l':
+		n, count := listIndex(tok), listLen(&s, doc, pstart)
+		switch {
+		case tok == "-" || n == count:
+			start, end = pend-1, pend-1
+		case n >= 0 && n < count:
+			start, end, err = findIndex(&s, doc, pstart, n)
+		default:
+			err = errors.New("invalid list index")
+		}
+
+	default:
+		err = errors.New("not a dictionary or list")
+	}
+	if err != nil {
+		return nil, p.error(len(p), err)
+	}
+
+	out := make([]byte, 0, len(doc)-(end-start)+len(key)+len(value))
+	out = append(out, doc[:start]...)
+	out = append(out, key...)
+	out = append(out, value...)
+	return append(out, doc[end:]...), nil
+}
+
+// Delete returns a copy of doc without the value p addresses. A dictionary
+// entry is removed along with its key, including any earlier entries with
+// the same key. All other bytes of doc are preserved exactly.
+func (p Pointer) Delete(doc []byte) ([]byte, error) {
+	if len(p) == 0 {
+		return nil, errors.New("cannot delete the whole document")
+	}
+
+	var s scanner
+	pstart, _, err := p.find(&s, doc, len(p)-1)
+	if err != nil {
+		return nil, err
+	}
+	tok := p[len(p)-1]
+
+	out := make([]byte, 0, len(doc))
+	switch doc[pstart] {
+	case 'd':
+		found := false
+		out = append(out, doc[:pstart+1]...)
+		off := pstart + 1
+		for doc[off] != 'e' {
+			k, koff := readKey(doc, off)
+			next, _ := scanValue(&s, doc, koff)
+			if string(k) == tok {
+				found = true
+			} else {
+				out = append(out, doc[off:next]...)
+			}
+			off = next
+		}
+		if !found {
+			return nil, p.error(len(p), errors.New("key not found"))
+		}
+		return append(out, doc[off:]...), nil
+
+	case 'l':
+		n := listIndex(tok)
+		if n < 0 {
+			return nil, p.error(len(p), errors.New("invalid list index"))
+		}
+		start, end, err := findIndex(&s, doc, pstart, n)
+		if err != nil {
+			return nil, p.error(len(p), err)
+		}
+		out = append(out, doc[:start]...)
+		return append(out, doc[end:]...), nil
+	}
+
+	return nil, p.error(len(p), errors.New("not a dictionary or list"))
+}
+
+// find returns the offsets of the value addressed by the first n tokens of
+// p. All of doc must be a single valid value.
+func (p Pointer) find(s *scanner, doc []byte, n int) (start, end int, err error) {
+	if err := checkValid(doc); err != nil {
+		return 0, 0, err
+	}
+
+	end = len(doc)
+	for i, tok := range p[:n] {
+		switch doc[start] {
+		case 'd':
+			start, end, err = findKey(s, doc, start, tok)
+		case 'l':
+			if idx := listIndex(tok); idx >= 0 {
+				start, end, err = findIndex(s, doc, start, idx)
+			} else {
+				err = errors.New("invalid list index")
+			}
+		default:
+			err = errors.New("not a dictionary or list")
+		}
+		if err != nil {
+			return 0, 0, p.error(i+1, err)
+		}
+	}
+	return start, end, nil
+}
+
+// error returns a PathError for the first n tokens of p.
+func (p Pointer) error(n int, err error) error {
+	path := make([]interface{}, n)
+	for i, tok := range p[:n] {
+		path[i] = tok
+	}
+	return &PathError{path, err.Error()}
+}
+
+// listIndex parses a reference token as a list index, returning -1 if it is
+// not a decimal number without leading zeros.
+func listIndex(tok string) int {
+	if tok == "" || (len(tok) > 1 && tok[0] == '0') {
+		return -1
+	}
+	for _, c := range tok {
+		if c < '0' || c > '9' {
+			return -1
+		}
+	}
+	n, err := strconv.Atoi(tok)
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// listLen returns the number of elements in the list that starts at
+// data[start].
+func listLen(s *scanner, data []byte, start int) int {
+	n := 0
+	for off := start + 1; data[off] != 'e'; n++ {
+		off, _ = scanValue(s, data, off)
+	}
+	return n
+}
+
+// dictInsertOffset returns the offset in the dictionary that starts at
+// data[start] at which an entry for key should be inserted: before the
+// first greater key, or before the closing 'e'.
+func dictInsertOffset(s *scanner, data []byte, start int, key string) int {
+	off := start + 1
+	for data[off] != 'e' {
+		k, koff := readKey(data, off)
+		if string(k) > key {
+			return off
+		}
+		off, _ = scanValue(s, data, koff)
+	}
+	return off
+}
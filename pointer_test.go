@@ -0,0 +1,128 @@
+package bencode
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePointer(t *testing.T) {
+	type testCase struct {
+		in     string
+		expect Pointer
+		err    bool
+	}
+
+	var cases = []testCase{
+		{``, Pointer{}, false},
+		{`/`, Pointer{""}, false},
+		{`/info/files/3/path`, Pointer{"info", "files", "3", "path"}, false},
+		{`/a~1b/c~0d/~01`, Pointer{"a/b", "c~d", "~1"}, false},
+		{`//x`, Pointer{"", "x"}, false},
+
+		{`info`, nil, true},
+		{`/a~`, nil, true},
+		{`/a~2`, nil, true},
+	}
+
+	for i, tt := range cases {
+		p, err := ParsePointer(tt.in)
+		if !tt.err && err != nil {
+			t.Errorf("#%d (%q): Unexpected err: %v", i, tt.in, err)
+			continue
+		}
+		if tt.err && err == nil {
+			t.Errorf("#%d (%q): Expected err is nil", i, tt.in)
+			continue
+		}
+		if !reflect.DeepEqual(p, tt.expect) {
+			t.Errorf("#%d: Val: %q != %q", i, p, tt.expect)
+		}
+		if !tt.err && p.String() != tt.in {
+			t.Errorf("#%d: String: %q != %q", i, p.String(), tt.in)
+		}
+	}
+}
+
+func TestPointer(t *testing.T) {
+	const doc = `d1:ai1e3:a/bi2e4:infod5:filesl1:x1:y1:zee1:zdee`
+
+	type testCase struct {
+		op     string // get, set or delete
+		ptr    string
+		value  string
+		expect string
+		err    bool
+	}
+
+	var cases = []testCase{
+		{"get", ``, ``, doc, false},
+		{"get", `/a`, ``, `i1e`, false},
+		{"get", `/a~1b`, ``, `i2e`, false},
+		{"get", `/info/files/2`, ``, `1:z`, false},
+		{"get", `/info/files/3`, ``, ``, true},
+		{"get", `/info/files/01`, ``, ``, true},
+		{"get", `/info/files/-`, ``, ``, true},
+		{"get", `/a/b`, ``, ``, true},
+		{"get", `/nope`, ``, ``, true},
+
+		// replace existing values
+		{"set", `/a`, `3:new`, `d1:a3:new3:a/bi2e4:infod5:filesl1:x1:y1:zee1:zdee`, false},
+		{"set", `/info/files/1`, `le`, `d1:ai1e3:a/bi2e4:infod5:filesl1:xle1:zee1:zdee`, false},
+		{"set", ``, `i0e`, `i0e`, false},
+
+		// insert keys in sorted position and append to lists
+		{"set", `/b`, `i9e`, `d1:ai1e3:a/bi2e1:bi9e4:infod5:filesl1:x1:y1:zee1:zdee`, false},
+		{"set", `/0`, `i9e`, `d1:0i9e1:ai1e3:a/bi2e4:infod5:filesl1:x1:y1:zee1:zdee`, false},
+		{"set", `/zz`, `i9e`, `d1:ai1e3:a/bi2e4:infod5:filesl1:x1:y1:zee1:zde2:zzi9ee`, false},
+		{"set", `/z/k`, `i9e`, `d1:ai1e3:a/bi2e4:infod5:filesl1:x1:y1:zee1:zd1:ki9eee`, false},
+		{"set", `/info/files/-`, `1:w`, `d1:ai1e3:a/bi2e4:infod5:filesl1:x1:y1:z1:wee1:zdee`, false},
+		{"set", `/info/files/3`, `1:w`, `d1:ai1e3:a/bi2e4:infod5:filesl1:x1:y1:z1:wee1:zdee`, false},
+		{"set", `/info/files/4`, `1:w`, ``, true},
+		{"set", `/a/b`, `1:w`, ``, true},
+		{"set", `/nope/b`, `1:w`, ``, true},
+		{"set", `/a`, `i1`, ``, true},
+		{"set", `/a`, `i1ei2e`, ``, true},
+
+		// delete
+		{"delete", `/a`, ``, `d3:a/bi2e4:infod5:filesl1:x1:y1:zee1:zdee`, false},
+		{"delete", `/info/files/0`, ``, `d1:ai1e3:a/bi2e4:infod5:filesl1:y1:zee1:zdee`, false},
+		{"delete", `/info`, ``, `d1:ai1e3:a/bi2e1:zdee`, false},
+		{"delete", `/nope`, ``, ``, true},
+		{"delete", `/info/files/3`, ``, ``, true},
+		{"delete", ``, ``, ``, true},
+	}
+
+	for i, tt := range cases {
+		p, err := ParsePointer(tt.ptr)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var out []byte
+		switch tt.op {
+		case "get":
+			out, err = p.Get([]byte(doc))
+		case "set":
+			out, err = p.Set([]byte(doc), []byte(tt.value))
+		case "delete":
+			out, err = p.Delete([]byte(doc))
+		}
+		if !tt.err && err != nil {
+			t.Errorf("#%d (%s %q): Unexpected err: %v", i, tt.op, tt.ptr, err)
+			continue
+		}
+		if tt.err && err == nil {
+			t.Errorf("#%d (%s %q): Expected err is nil", i, tt.op, tt.ptr)
+			continue
+		}
+		if string(out) != tt.expect {
+			t.Errorf("#%d: Val: %q != %q", i, out, tt.expect)
+		}
+	}
+
+	// duplicate keys are all removed by a delete
+	p := Pointer{"a"}
+	if out, err := p.Delete([]byte(`d1:ai1e1:ai2e1:bi3ee`)); err != nil || string(out) != `d1:bi3ee` {
+		t.Errorf("got %q, %v", out, err)
+	}
+}
@@ -0,0 +1,245 @@
+package bencode
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// readFrame is an open list or dictionary in a Decoder.
+type readFrame struct {
+	dict      bool
+	hasKey    bool                // a key has been read from the dictionary
+	lastKey   string              // the last key read from the dictionary
+	wantValue bool                // a key has been read and its value has not
+	seen      map[string]struct{} // the keys read, when duplicates fail
+	single    bool                // the frame holds the one value read by an UnmarshalerFrom
+	read      bool                // the value of a single frame has been read
+}
+
+// BeginList reads the start of a list. Values read until the matching End
+// are its elements, and More reports whether any remain.
+func (d *Decoder) BeginList() error {
+	return d.beginContainer('l', false)
+}
+
+// BeginDict reads the start of a dictionary. Until the matching End, each
+// entry is read as a call to Key followed by its value, and More reports
+// whether any remain. Keys are checked as SetFailOnUnorderedKeys and
+// SetFailOnDuplicateKeys ask.
+func (d *Decoder) BeginDict() error {
+	return d.beginContainer('d', true)
+}
+
+func (d *Decoder) beginContainer(c byte, dict bool) error {
+	if err := d.beforeValue(); err != nil {
+		return err
+	}
+	ch, err := d.readByte()
+	if err != nil {
+		return err
+	}
+	if ch != c {
+		return fmt.Errorf("Expected %q, got %q", c, ch)
+	}
+
+	d.depth++
+	if err := d.checkLimits(d.depth, 0); err != nil {
+		return err
+	}
+	f := readFrame{dict: dict}
+	if dict && d.failDuplicate {
+		f.seen = make(map[string]struct{})
+	}
+	d.stack = append(d.stack, f)
+	return nil
+}
+
+// More reports whether the innermost list or dictionary has more elements
+// or entries to read.
+func (d *Decoder) More() (bool, error) {
+	if len(d.stack) == 0 || d.stack[len(d.stack)-1].single {
+		return false, errors.New("More called without an open list or dictionary")
+	}
+	ch, err := d.peekByte()
+	if err != nil {
+		return false, err
+	}
+	return ch != 'e', nil
+}
+
+// Key reads the key of the next entry of the innermost dictionary.
+func (d *Decoder) Key() (string, error) {
+	if len(d.stack) == 0 || !d.stack[len(d.stack)-1].dict {
+		return "", errors.New("Key called outside of a dictionary")
+	}
+	f := &d.stack[len(d.stack)-1]
+	if f.wantValue {
+		return "", fmt.Errorf("Key called before the value of key %q was read", f.lastKey)
+	}
+
+	var key string
+	if err := d.decodeString(reflect.ValueOf(&key).Elem()); err != nil {
+		return "", err
+	}
+	if f.hasKey && d.failUnordered && f.lastKey > key {
+		return "", fmt.Errorf("unordered dictionary: %q appears before %q", f.lastKey, key)
+	}
+	if f.seen != nil {
+		if _, ok := f.seen[key]; ok {
+			return "", fmt.Errorf("duplicate dictionary key: %q", key)
+		}
+		f.seen[key] = struct{}{}
+	}
+	f.hasKey, f.lastKey, f.wantValue = true, key, true
+	return key, nil
+}
+
+// End reads the end of the innermost list or dictionary, which must have
+// no elements or entries left.
+func (d *Decoder) End() error {
+	if len(d.stack) == 0 || d.stack[len(d.stack)-1].single {
+		return errors.New("End called without an open list or dictionary")
+	}
+	if f := d.stack[len(d.stack)-1]; f.wantValue {
+		return fmt.Errorf("End called before the value of key %q was read", f.lastKey)
+	}
+	ch, err := d.readByte()
+	if err != nil {
+		return err
+	}
+	if ch != 'e' {
+		return fmt.Errorf("End called with values left, got %q", ch)
+	}
+	d.depth--
+	d.stack = d.stack[:len(d.stack)-1]
+	return nil
+}
+
+// ReadInt reads an integer value.
+func (d *Decoder) ReadInt() (int64, error) {
+	if err := d.beforeValue(); err != nil {
+		return 0, err
+	}
+	var n int64
+	if err := d.expect('i'); err != nil {
+		return 0, err
+	}
+	err := d.decodeInt(reflect.ValueOf(&n).Elem())
+	return n, err
+}
+
+// ReadUint reads an integer value that is not negative, including one
+// above the range of an int64.
+func (d *Decoder) ReadUint() (uint64, error) {
+	if err := d.beforeValue(); err != nil {
+		return 0, err
+	}
+	var n uint64
+	if err := d.expect('i'); err != nil {
+		return 0, err
+	}
+	err := d.decodeInt(reflect.ValueOf(&n).Elem())
+	return n, err
+}
+
+// ReadString reads a string value.
+func (d *Decoder) ReadString() (string, error) {
+	if err := d.beforeValue(); err != nil {
+		return "", err
+	}
+	if err := d.expect('0'); err != nil {
+		return "", err
+	}
+	var s string
+	err := d.decodeString(reflect.ValueOf(&s).Elem())
+	return s, err
+}
+
+// PeekKind reports the kind of the next value without reading it, or
+// InvalidKind if the innermost list or dictionary ends next.
+func (d *Decoder) PeekKind() (Kind, error) {
+	ch, err := d.peekByte()
+	if err != nil {
+		return InvalidKind, err
+	}
+	switch {
+	case ch == 'i':
+		return IntKind, nil
+	case '0' <= ch && ch <= '9':
+		return StringKind, nil
+	case ch == 'l':
+		return ListKind, nil
+	case ch == 'd':
+		return DictKind, nil
+	case ch == 'e' && len(d.stack) > 0:
+		return InvalidKind, nil
+	}
+	return InvalidKind, fmt.Errorf("invalid character %q looking for value", ch)
+}
+
+// Skip reads the next value and discards it.
+func (d *Decoder) Skip() error {
+	if err := d.beforeValue(); err != nil {
+		return err
+	}
+	_, err := d.readRaw()
+	return err
+}
+
+// expect checks that the next value starts with c, where '0' stands for
+// any digit.
+func (d *Decoder) expect(c byte) error {
+	ch, err := d.peekByte()
+	if err != nil {
+		return err
+	}
+	if ch == c || c == '0' && '0' <= ch && ch <= '9' {
+		return nil
+	}
+	if c == '0' {
+		return fmt.Errorf("Expected a string, got %q", ch)
+	}
+	return fmt.Errorf("Expected %q, got %q", c, ch)
+}
+
+// beforeValue checks that a value may be read at this point and records
+// that it has been.
+func (d *Decoder) beforeValue() error {
+	if len(d.stack) == 0 {
+		return nil
+	}
+	f := &d.stack[len(d.stack)-1]
+	if f.single {
+		if f.read {
+			return errors.New("more than one value read by UnmarshalBencodeFrom")
+		}
+		f.read = true
+	}
+	if f.dict {
+		if !f.wantValue {
+			return errors.New("value read in a dictionary where a key was expected")
+		}
+		f.wantValue = false
+	}
+	return nil
+}
+
+// unmarshalFrom calls the UnmarshalBencodeFrom method of u, checking that it
+// reads exactly one complete value.
+func (d *Decoder) unmarshalFrom(u UnmarshalerFrom) error {
+	saved, depth := d.stack, d.depth
+	d.stack = append(d.stack[len(d.stack):], readFrame{single: true})
+	defer func() { d.stack, d.depth = saved, depth }()
+
+	if err := u.UnmarshalBencodeFrom(d); err != nil {
+		return err
+	}
+	switch {
+	case len(d.stack) > 1:
+		return fmt.Errorf("UnmarshalBencodeFrom for type %T left %d lists or dictionaries open", u, len(d.stack)-1)
+	case !d.stack[0].read:
+		return fmt.Errorf("UnmarshalBencodeFrom for type %T read no value", u)
+	}
+	return nil
+}
@@ -0,0 +1,324 @@
+package bencode
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// streamPair implements both the streaming and the byte interfaces, and the
+// streaming ones are preferred.
+type streamPair struct {
+	A int64
+	B string
+}
+
+func (p streamPair) MarshalBencodeTo(e *Encoder) error {
+	if err := e.BeginList(); err != nil {
+		return err
+	}
+	if err := e.WriteInt(p.A); err != nil {
+		return err
+	}
+	if err := e.WriteString(p.B); err != nil {
+		return err
+	}
+	return e.End()
+}
+
+func (p *streamPair) UnmarshalBencodeFrom(d *Decoder) error {
+	if err := d.BeginList(); err != nil {
+		return err
+	}
+	var err error
+	if p.A, err = d.ReadInt(); err != nil {
+		return err
+	}
+	if p.B, err = d.ReadString(); err != nil {
+		return err
+	}
+	return d.End()
+}
+
+func (p streamPair) MarshalBencode() ([]byte, error) { return []byte("i0e"), nil }
+
+func (p *streamPair) UnmarshalBencode([]byte) error { return nil }
+
+// streamFunc implements the streaming interfaces with the given functions.
+type streamFunc struct {
+	write func(e *Encoder) error
+	read  func(d *Decoder) error
+}
+
+func (f streamFunc) MarshalBencodeTo(e *Encoder) error { return f.write(e) }
+
+func (f *streamFunc) UnmarshalBencodeFrom(d *Decoder) error { return f.read(d) }
+
+func TestStreamingRoundTrip(t *testing.T) {
+	var x struct {
+		P    streamPair   `bencode:"p"`
+		List []streamPair `bencode:"list"`
+		Ptr  *streamPair  `bencode:"ptr"`
+	}
+	x.P = streamPair{1, "a"}
+	x.List = []streamPair{{2, "b"}, {3, "c"}}
+	x.Ptr = &streamPair{4, "d"}
+
+	got, err := EncodeString(x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const expect = `d4:listlli2e1:beli3e1:cee1:pli1e1:ae3:ptrli4e1:dee`
+	if got != expect {
+		t.Fatalf("%q != %q", got, expect)
+	}
+
+	var back struct {
+		P    streamPair   `bencode:"p"`
+		List []streamPair `bencode:"list"`
+		Ptr  *streamPair  `bencode:"ptr"`
+	}
+	if err := DecodeString(got, &back); err != nil {
+		t.Fatal(err)
+	}
+	if back.P != x.P || !reflect.DeepEqual(back.List, x.List) || *back.Ptr != *x.Ptr {
+		t.Errorf("got %#v", back)
+	}
+}
+
+func TestMarshalerToErrors(t *testing.T) {
+	var cases = []func(e *Encoder) error{
+		// no value
+		func(e *Encoder) error { return nil },
+		// two values
+		func(e *Encoder) error {
+			if err := e.WriteInt(1); err != nil {
+				return err
+			}
+			return e.WriteInt(2)
+		},
+		// containers left open
+		func(e *Encoder) error { return e.BeginList() },
+		// no container to end
+		func(e *Encoder) error { return e.End() },
+		// a nil value writes nothing
+		func(e *Encoder) error { return e.Encode((*int)(nil)) },
+	}
+
+	for i, write := range cases {
+		var buf bytes.Buffer
+		err := NewEncoder(&buf).Encode([]streamFunc{{write: write}})
+		if err == nil {
+			t.Errorf("#%d: Expected err is nil", i)
+		}
+	}
+}
+
+func TestUnmarshalerFromErrors(t *testing.T) {
+	type testCase struct {
+		in   string
+		read func(d *Decoder) error
+	}
+
+	var cases = []testCase{
+		// no value
+		{`i1e`, func(d *Decoder) error { return nil }},
+		// two values
+		{`li1ei2ee`, func(d *Decoder) error {
+			if _, err := d.ReadInt(); err != nil {
+				return err
+			}
+			_, err := d.ReadInt()
+			return err
+		}},
+		// containers left open
+		{`li1ee`, func(d *Decoder) error { return d.BeginList() }},
+		// no container to end
+		{`i1e`, func(d *Decoder) error { return d.End() }},
+		// the wrong kind of value
+		{`i1e`, func(d *Decoder) error { _, err := d.ReadString(); return err }},
+	}
+
+	for i, tt := range cases {
+		v := []streamFunc{{read: tt.read}}
+		if err := DecodeString("l"+tt.in+"e", &v); err == nil {
+			t.Errorf("#%d: Expected err is nil", i)
+		}
+	}
+}
+
+func TestDecoderTokens(t *testing.T) {
+	d := NewDecoder(strings.NewReader(`d1:ai-1e1:bli1e3:fooe1:cd1:xi1ee1:d3:bare`))
+
+	var got []string
+	check := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("Unexpected err: %v", err)
+		}
+	}
+
+	check(d.BeginDict())
+	for {
+		more, err := d.More()
+		check(err)
+		if !more {
+			break
+		}
+		key, err := d.Key()
+		check(err)
+		got = append(got, key)
+
+		kind, err := d.PeekKind()
+		check(err)
+		switch kind {
+		case IntKind:
+			n, err := d.ReadInt()
+			check(err)
+			if n != -1 {
+				t.Errorf("got %d", n)
+			}
+		case ListKind:
+			check(d.BeginList())
+			n, err := d.ReadInt()
+			check(err)
+			var s string
+			check(d.Decode(&s))
+			if n != 1 || s != "foo" {
+				t.Errorf("got %d, %q", n, s)
+			}
+			check(d.End())
+		case DictKind:
+			check(d.Skip())
+		case StringKind:
+			s, err := d.ReadString()
+			check(err)
+			if s != "bar" {
+				t.Errorf("got %q", s)
+			}
+		}
+	}
+	check(d.End())
+
+	if strings.Join(got, ",") != "a,b,c,d" {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestDecoderReadUint(t *testing.T) {
+	type testCase struct {
+		in  string
+		out uint64
+		err bool
+	}
+
+	var cases = []testCase{
+		{`i0e`, 0, false},
+		{`i6881e`, 6881, false},
+		{`i18446744073709551615e`, 1<<64 - 1, false},
+
+		{`i-1e`, 0, true},
+		{`i18446744073709551616e`, 0, true},
+		{`1:a`, 0, true},
+	}
+
+	for i, tt := range cases {
+		n, err := NewDecoder(strings.NewReader(tt.in)).ReadUint()
+		if !tt.err && err != nil {
+			t.Errorf("#%d: Unexpected err: %v", i, err)
+			continue
+		}
+		if tt.err && err == nil {
+			t.Errorf("#%d: Expected err is nil", i)
+			continue
+		}
+		if n != tt.out {
+			t.Errorf("#%d: Val: %d != %d", i, n, tt.out)
+		}
+	}
+}
+
+func TestDecoderTokensErrors(t *testing.T) {
+	type testCase struct {
+		in   string
+		read func(d *Decoder) error
+	}
+
+	var cases = []testCase{
+		// a value where a key was expected
+		{`d1:ai1ee`, func(d *Decoder) error {
+			if err := d.BeginDict(); err != nil {
+				return err
+			}
+			_, err := d.ReadString()
+			return err
+		}},
+		// a key where a value was expected
+		{`d1:a1:be`, func(d *Decoder) error {
+			if err := d.BeginDict(); err != nil {
+				return err
+			}
+			if _, err := d.Key(); err != nil {
+				return err
+			}
+			_, err := d.Key()
+			return err
+		}},
+		// a key outside of a dictionary
+		{`li1ee`, func(d *Decoder) error {
+			if err := d.BeginList(); err != nil {
+				return err
+			}
+			_, err := d.Key()
+			return err
+		}},
+		// End with values left
+		{`li1ee`, func(d *Decoder) error {
+			if err := d.BeginList(); err != nil {
+				return err
+			}
+			return d.End()
+		}},
+		// the wrong kind of container
+		{`li1ee`, (*Decoder).BeginDict},
+		// unordered keys
+		{`d1:bi1e1:ai1ee`, func(d *Decoder) error {
+			d.SetFailOnUnorderedKeys(true)
+			if err := d.BeginDict(); err != nil {
+				return err
+			}
+			for i := 0; i < 2; i++ {
+				if _, err := d.Key(); err != nil {
+					return err
+				}
+				if err := d.Skip(); err != nil {
+					return err
+				}
+			}
+			return nil
+		}},
+		// duplicate keys
+		{`d1:ai1e1:ai1ee`, func(d *Decoder) error {
+			d.SetFailOnDuplicateKeys(true)
+			if err := d.BeginDict(); err != nil {
+				return err
+			}
+			for i := 0; i < 2; i++ {
+				if _, err := d.Key(); err != nil {
+					return err
+				}
+				if err := d.Skip(); err != nil {
+					return err
+				}
+			}
+			return nil
+		}},
+	}
+
+	for i, tt := range cases {
+		if err := tt.read(NewDecoder(strings.NewReader(tt.in))); err == nil {
+			t.Errorf("#%d: Expected err is nil", i)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package bencode
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// A Codec encodes and decodes the values of a type that cannot implement
+// Marshaler and Unmarshaler itself, such as a type from another package.
+// Marshal returns the bencode for v, which must be exactly one valid value.
+// Unmarshal stores the value in data into v, which is addressable; data is
+// a valid bencode value and must be copied if it is retained.
+// Either function may be nil, in which case the Codec for the type in the
+// global registry is used, or the type is handled as it would be without
+// one.
+type Codec struct {
+	Marshal   func(v reflect.Value) ([]byte, error)
+	Unmarshal func(data []byte, v reflect.Value) error
+}
+
+// A Registry maps types to the Codecs used for them, and interface types
+// to the Unions of concrete types they may hold. The Encoder and Decoder
+// consult the Registry of the Options that created them, then the global
+// registry that Register and RegisterUnion add to, before checking for the
+// Marshaler, Unmarshaler and encoding.Text interfaces. A Registry is safe
+// for concurrent use, and is meant to be filled in before it is used.
+type Registry struct {
+	mu    sync.Mutex // serializes changes
+	state atomic.Pointer[registryState]
+}
+
+// registryState is the contents of a Registry. It is replaced as a whole
+// when the Registry changes, so that lookups need no lock.
+type registryState struct {
+	codecs map[reflect.Type]Codec
+	unions map[reflect.Type]*union
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return new(Registry)
+}
+
+// update stores a copy of the state of r changed by fn.
+func (r *Registry) update(fn func(*registryState)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	state := &registryState{
+		codecs: make(map[reflect.Type]Codec),
+		unions: make(map[reflect.Type]*union),
+	}
+	if old := r.state.Load(); old != nil {
+		for k, v := range old.codecs {
+			state.codecs[k] = v
+		}
+		for k, v := range old.unions {
+			state.unions[k] = v
+		}
+	}
+	fn(state)
+	r.state.Store(state)
+}
+
+// Register sets the Codec used for values of type t, replacing any that
+// was registered before.
+func (r *Registry) Register(t reflect.Type, c Codec) {
+	r.update(func(state *registryState) { state.codecs[t] = c })
+}
+
+func (r *Registry) lookup(t reflect.Type) (Codec, bool) {
+	if r == nil {
+		return Codec{}, false
+	}
+	state := r.state.Load()
+	if state == nil {
+		return Codec{}, false
+	}
+	c, ok := state.codecs[t]
+	return c, ok
+}
+
+var globalRegistry Registry
+
+// Register sets the Codec used for values of type t by every Encoder and
+// Decoder, unless the Registry of their Options has one for t.
+func Register(t reflect.Type, c Codec) {
+	globalRegistry.Register(t, c)
+}
+
+// RegisterType sets the Codec for values of type T in r, or in the global
+// registry if r is nil, from typed functions. Either function may be nil.
+func RegisterType[T any](r *Registry, marshal func(T) ([]byte, error), unmarshal func([]byte, *T) error) {
+	var c Codec
+	if marshal != nil {
+		c.Marshal = func(v reflect.Value) ([]byte, error) {
+			return marshal(v.Interface().(T))
+		}
+	}
+	if unmarshal != nil {
+		c.Unmarshal = func(data []byte, v reflect.Value) error {
+			return unmarshal(data, v.Addr().Interface().(*T))
+		}
+	}
+
+	if r == nil {
+		r = &globalRegistry
+	}
+	r.Register(reflect.TypeFor[T](), c)
+}
+
+// lookupMarshal returns the Marshal function of the Codec for t from r or
+// the global registry.
+func lookupMarshal(r *Registry, t reflect.Type) func(reflect.Value) ([]byte, error) {
+	if c, ok := r.lookup(t); ok && c.Marshal != nil {
+		return c.Marshal
+	}
+	c, _ := globalRegistry.lookup(t)
+	return c.Marshal
+}
+
+// lookupUnmarshal returns the Unmarshal function of the Codec for t from r
+// or the global registry.
+func lookupUnmarshal(r *Registry, t reflect.Type) func([]byte, reflect.Value) error {
+	if c, ok := r.lookup(t); ok && c.Unmarshal != nil {
+		return c.Unmarshal
+	}
+	c, _ := globalRegistry.lookup(t)
+	return c.Unmarshal
+}
+
+// codecMarshaler adapts a Codec to the Marshaler interface for a value.
+type codecMarshaler struct {
+	marshal func(reflect.Value) ([]byte, error)
+	v       reflect.Value
+}
+
+func (m codecMarshaler) MarshalBencode() ([]byte, error) {
+	return m.marshal(m.v)
+}
+
+// codecUnmarshaler adapts a Codec to the Unmarshaler interface for a value.
+type codecUnmarshaler struct {
+	unmarshal func([]byte, reflect.Value) error
+	v         reflect.Value
+}
+
+func (u codecUnmarshaler) UnmarshalBencode(data []byte) error {
+	return u.unmarshal(data, u.v)
+}
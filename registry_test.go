@@ -0,0 +1,184 @@
+package bencode
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// registryPoint is encoded as a list by a Codec in the global registry.
+type registryPoint struct{ X, Y int }
+
+func init() {
+	RegisterType(nil,
+		func(p registryPoint) ([]byte, error) {
+			return EncodeBytes([]int{p.X, p.Y})
+		},
+		func(data []byte, p *registryPoint) error {
+			var xy [2]int
+			if err := DecodeBytes(data, &xy); err != nil {
+				return err
+			}
+			p.X, p.Y = xy[0], xy[1]
+			return nil
+		})
+}
+
+func urlRegistry() *Registry {
+	r := NewRegistry()
+	RegisterType(r,
+		func(u url.URL) ([]byte, error) {
+			return EncodeBytes(u.String())
+		},
+		func(data []byte, u *url.URL) error {
+			var s string
+			if err := DecodeBytes(data, &s); err != nil {
+				return err
+			}
+			p, err := url.Parse(s)
+			if err != nil {
+				return err
+			}
+			*u = *p
+			return nil
+		})
+	return r
+}
+
+func TestRegistryEncode(t *testing.T) {
+	opts := Options{Registry: urlRegistry()}
+	u, _ := url.Parse("http://tracker/announce")
+
+	type testCase struct {
+		val    interface{}
+		expect string
+	}
+
+	var cases = []testCase{
+		{registryPoint{1, 2}, `li1ei2ee`},
+		{&registryPoint{1, 2}, `li1ei2ee`},
+		{[]interface{}{registryPoint{3, 4}}, `lli3ei4eee`},
+		{map[string]*registryPoint{"p": {5, 6}}, `d1:pli5ei6eee`},
+		{struct{ P registryPoint }{registryPoint{7, 8}}, `d1:Pli7ei8eee`},
+		{u, `23:http://tracker/announce`},
+		{struct {
+			U *url.URL `bencode:"u"`
+			V url.URL  `bencode:"v"`
+		}{u, *u}, `d1:u23:http://tracker/announce1:v23:http://tracker/announcee`},
+	}
+
+	for i, tt := range cases {
+		got, err := opts.Marshal(tt.val)
+		if err != nil {
+			t.Errorf("#%d: Unexpected err: %v", i, err)
+			continue
+		}
+		if string(got) != tt.expect {
+			t.Errorf("#%d: Val: %q != %q", i, got, tt.expect)
+		}
+	}
+
+	// the per-Options registry is not used by the package-level functions
+	if got, err := EncodeString(u); err != nil || strings.Contains(got, "23:") {
+		t.Errorf("got %q, %v", got, err)
+	}
+}
+
+func TestRegistryDecode(t *testing.T) {
+	opts := Options{Registry: urlRegistry()}
+
+	var x struct {
+		P  registryPoint   `bencode:"p"`
+		PP *registryPoint  `bencode:"pp"`
+		L  []registryPoint `bencode:"l"`
+		U  *url.URL        `bencode:"u"`
+	}
+	in := `d1:llli1ei2eeli3ei4eee1:pli5ei6ee2:ppli7ei8ee1:u23:http://tracker/announcee`
+	if err := opts.Unmarshal([]byte(in), &x); err != nil {
+		t.Fatal(err)
+	}
+
+	if x.P != (registryPoint{5, 6}) || x.PP == nil || *x.PP != (registryPoint{7, 8}) ||
+		!reflect.DeepEqual(x.L, []registryPoint{{1, 2}, {3, 4}}) ||
+		x.U == nil || x.U.Host != "tracker" {
+		t.Errorf("got %+v", x)
+	}
+
+	if err := opts.Unmarshal([]byte(`3:foo`), &x.P); err == nil {
+		t.Errorf("Expected err is nil")
+	}
+}
+
+func TestRegistryUnnamed(t *testing.T) {
+	r := NewRegistry()
+	RegisterType(r,
+		func(l []string) ([]byte, error) {
+			return EncodeBytes(strings.Join(l, ","))
+		},
+		func(data []byte, l *[]string) error {
+			var s string
+			if err := DecodeBytes(data, &s); err != nil {
+				return err
+			}
+			*l = strings.Split(s, ",")
+			return nil
+		})
+	opts := Options{Registry: r}
+
+	type S struct {
+		L []string            `bencode:"l"`
+		M map[string][]string `bencode:"m"`
+	}
+	val := S{L: []string{"a", "b"}, M: map[string][]string{"x": {"c"}}}
+
+	// a Codec of an unnamed type is used for fields and map values both ways
+	got, err := opts.Marshal(val)
+	if err != nil || string(got) != `d1:l3:a,b1:md1:x1:cee` {
+		t.Fatalf("got %q, %v", got, err)
+	}
+	var back S
+	if err := opts.Unmarshal(got, &back); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(back, val) {
+		t.Errorf("got %+v", back)
+	}
+}
+
+func TestRegistryPrecedence(t *testing.T) {
+	r := NewRegistry()
+	RegisterType(r, func(p registryPoint) ([]byte, error) {
+		return []byte(`i0e`), nil
+	}, nil)
+	opts := Options{Registry: r}
+
+	// the Options registry wins over the global one
+	if got, err := opts.Marshal(registryPoint{1, 2}); err != nil || string(got) != `i0e` {
+		t.Errorf("got %q, %v", got, err)
+	}
+
+	// a nil Unmarshal falls back to the global Codec
+	var p registryPoint
+	if err := opts.Unmarshal([]byte(`li1ei2ee`), &p); err != nil || p != (registryPoint{1, 2}) {
+		t.Errorf("got %v, %v", p, err)
+	}
+
+	// Codec output is validated like Marshaler output
+	errCodec := errors.New("codec failed")
+	r.Register(reflect.TypeFor[registryPoint](), Codec{
+		Marshal: func(v reflect.Value) ([]byte, error) {
+			if v.Interface().(registryPoint).X < 0 {
+				return nil, errCodec
+			}
+			return []byte(`i1`), nil
+		},
+	})
+	if _, err := opts.Marshal(registryPoint{1, 2}); err == nil || !strings.Contains(err.Error(), "registryPoint") {
+		t.Errorf("got %v", err)
+	}
+	if _, err := opts.Marshal(registryPoint{-1, 2}); err != errCodec {
+		t.Errorf("got %v", err)
+	}
+}
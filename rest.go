@@ -0,0 +1,76 @@
+package bencode
+
+import (
+	"fmt"
+	"reflect"
+)
+
+var reflectRestType = reflect.TypeOf(map[string]RawMessage(nil))
+
+// appendRest adds the entries of the rest field v to dict, marked with the
+// rest option.
+func appendRest(dict dictionary, v reflect.Value) (dictionary, error) {
+	if v.Type() != reflectRestType {
+		return nil, fmt.Errorf("Can't encode rest field of type %s", v.Type())
+	}
+	iter := v.MapRange()
+	for iter.Next() {
+		dict = append(dict, definition{iter.Key().String(), iter.Value(), "rest"})
+	}
+	return dict, nil
+}
+
+// mergeRest removes the entries of rest fields from the sorted dict whose
+// keys are written by other fields.
+func mergeRest(dict dictionary) dictionary {
+	hasRest := false
+	for _, def := range dict {
+		if def.opts == "rest" {
+			hasRest = true
+			break
+		}
+	}
+	if !hasRest {
+		return dict
+	}
+
+	fields := make(map[string]bool, len(dict))
+	for _, def := range dict {
+		if def.opts != "rest" {
+			fields[def.key] = true
+		}
+	}
+	out := dict[:0]
+	for _, def := range dict {
+		if def.opts == "rest" && fields[def.key] {
+			continue
+		}
+		out = append(out, def)
+	}
+	return out
+}
+
+// restField returns the field of the struct v, or of a struct embedded in
+// it, with the rest option, or the zero Value if there is none. The option
+// is described in the package documentation.
+func restField(v reflect.Value) reflect.Value {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		if _, opts := parseTag(f.Tag.Get("bencode")); opts.Contains("rest") {
+			return v.Field(i)
+		}
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath == "" && f.Anonymous && f.Tag == "" && f.Type.Kind() == reflect.Struct {
+			if rest := restField(v.Field(i)); rest.IsValid() {
+				return rest
+			}
+		}
+	}
+	return reflect.Value{}
+}
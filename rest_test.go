@@ -0,0 +1,103 @@
+package bencode
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRestRoundTrip(t *testing.T) {
+	type info struct {
+		Name   string                `bencode:"name"`
+		Length int64                 `bencode:"length,omitempty"`
+		Rest   map[string]RawMessage `bencode:",rest"`
+	}
+	type torrent struct {
+		Announce string                `bencode:"announce"`
+		Info     info                  `bencode:"info"`
+		Extra    map[string]RawMessage `bencode:"extra,rest"`
+	}
+
+	var cases = []string{
+		`d8:announce3:url4:infod6:lengthi5e4:name1:aee`,
+		`d8:announce3:url4:infod5:filesld6:lengthi1eee4:name1:a6:pieces3:xyz7:privatei1eee`,
+		`d7:comment2:hi8:announce3:url4:infod4:name1:ae1:zli1eee`,
+		`d1:a0:8:announce3:url4:infod4:name1:aee`,
+	}
+
+	for i, in := range cases {
+		var tor torrent
+		if err := DecodeString(in, &tor); err != nil {
+			t.Errorf("#%d: Unexpected err: %v", i, err)
+			continue
+		}
+		canonical, err := Canonicalize(nil, []byte(in))
+		if err != nil {
+			t.Errorf("#%d: Unexpected err: %v", i, err)
+			continue
+		}
+		got, err := EncodeString(tor)
+		if err != nil {
+			t.Errorf("#%d: Unexpected err: %v", i, err)
+			continue
+		}
+		if got != string(canonical) {
+			t.Errorf("#%d: Val: %q != %q", i, got, canonical)
+		}
+	}
+
+	// the values of unknown keys are kept
+	var tor torrent
+	if err := DecodeString(`d8:announce3:url4:infod4:name1:a7:privatei1ee1:zli1eee`, &tor); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tor.Extra, map[string]RawMessage{"z": RawMessage("li1ee")}) ||
+		!reflect.DeepEqual(tor.Info.Rest, map[string]RawMessage{"private": RawMessage("i1e")}) {
+		t.Errorf("got %#v", tor)
+	}
+
+	// an entry with the key of a written field is left out, but one with the
+	// key of an omitted field is written
+	tor.Info.Rest["name"] = RawMessage("1:b")
+	tor.Info.Rest["length"] = RawMessage("i9e")
+	got, err := EncodeString(tor.Info)
+	if err != nil || got != `d6:lengthi9e4:name1:a7:privatei1ee` {
+		t.Errorf("got %q, %v", got, err)
+	}
+}
+
+func TestRestOptions(t *testing.T) {
+	type outer struct {
+		A int `bencode:"a"`
+		RestEmbedded
+	}
+
+	// unknown keys are kept even when they are disallowed
+	var opts Options
+	opts.DisallowUnknownFields = true
+	var o outer
+	if err := opts.Unmarshal([]byte(`d1:ai1e1:bi2ee`), &o); err != nil {
+		t.Fatal(err)
+	}
+	if o.A != 1 || !reflect.DeepEqual(o.Rest, map[string]RawMessage{"b": RawMessage("i2e")}) {
+		t.Errorf("got %#v", o)
+	}
+	if got, err := EncodeString(o); err != nil || got != `d1:ai1e1:bi2ee` {
+		t.Errorf("got %q, %v", got, err)
+	}
+
+	// the rest field only holds RawMessages
+	type bad struct {
+		Rest map[string]string `bencode:",rest"`
+	}
+	if err := DecodeString(`d1:a1:be`, &bad{}); err == nil {
+		t.Errorf("Expected err is nil")
+	}
+	if _, err := EncodeString(bad{}); err == nil {
+		t.Errorf("Expected err is nil")
+	}
+}
+
+// RestEmbedded is exported so that its fields are seen when embedded.
+type RestEmbedded struct {
+	Rest map[string]RawMessage `bencode:",rest"`
+}
@@ -0,0 +1,302 @@
+package bencode
+
+import (
+	"fmt"
+	"math"
+)
+
+// A SyntaxError describes malformed bencode and the offset at which it was
+// detected.
+type SyntaxError struct {
+	msg    string
+	Offset int64 // number of bytes read before the error occurred
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s at offset %d", e.msg, e.Offset)
+}
+
+// values returned by scanner.step
+const (
+	scanContinue = iota // the byte was accepted and the value is incomplete
+	scanEnd             // the byte completed the value
+	scanError           // the byte is invalid; the error is in scanner.err
+)
+
+// scanner states
+const (
+	stateValue     = iota // start of a value, or 'e' closing a list
+	stateKey              // start of a dictionary key, or 'e' closing the dictionary
+	stateIntStart         // after the 'i' of an integer
+	stateIntNeg           // after the '-' of a negative integer
+	stateIntDigits        // inside the digits of an integer
+	stateStrLen           // inside the length prefix of a string
+	stateStrBody          // inside the body of a string
+	stateDone             // a complete value has been scanned
+)
+
+// scanner is a state machine that recognizes a single bencode value fed to
+// it one byte at a time. It accepts every value the Decoder can parse,
+// including integers with leading zeros and dictionaries with unordered keys.
+// The zero value is ready to use, and a scanner does not allocate unless
+// containers are nested more than 64 deep.
+type scanner struct {
+	state  int
+	strlen int64  // remaining bytes of a string body, or the length read so far
+	key    bool   // the string being scanned is a dictionary key
+	depth  int    // number of open containers
+	dicts  uint64 // bit i is set when the container at depth i+1 is a dictionary
+	deep   []bool // whether containers deeper than 64 are dictionaries
+	off    int64  // number of bytes consumed
+	err    error
+}
+
+// reset prepares the scanner to scan a new value that starts at offset off.
+func (s *scanner) reset(off int64) {
+	s.state = stateValue
+	s.strlen = 0
+	s.key = false
+	s.depth = 0
+	s.deep = s.deep[:0]
+	s.off = off
+	s.err = nil
+}
+
+// step consumes the next byte of input.
+func (s *scanner) step(c byte) int {
+	s.off++
+
+	switch s.state {
+	case stateKey:
+		switch {
+		case c == 'e':
+			return s.pop()
+		case '0' <= c && c <= '9':
+			s.key = true
+			s.state, s.strlen = stateStrLen, int64(c-'0')
+			return scanContinue
+		}
+		return s.error(c, "dictionary key")
+
+	case stateValue:
+		switch {
+		case c == 'i':
+			s.state = stateIntStart
+			return scanContinue
+		case '0' <= c && c <= '9':
+			s.state, s.strlen = stateStrLen, int64(c-'0')
+			return scanContinue
+		case c == 'l':
+			s.push(false)
+			s.state = stateValue
+			return scanContinue
+		case c == 'd':
+			s.push(true)
+			s.state = stateKey
+			return scanContinue
+		case c == 'e' && s.depth > 0 && !s.isDict():
+			return s.pop()
+		case c == 'e' && s.depth > 0:
+			return s.error(c, "dictionary value")
+		}
+		return s.error(c, "value")
+
+	case stateIntStart:
+		switch {
+		case c == '-':
+			s.state = stateIntNeg
+			return scanContinue
+		case '0' <= c && c <= '9':
+			s.state = stateIntDigits
+			return scanContinue
+		}
+		return s.error(c, "integer")
+
+	case stateIntNeg:
+		if '0' <= c && c <= '9' {
+			s.state = stateIntDigits
+			return scanContinue
+		}
+		return s.error(c, "integer")
+
+	case stateIntDigits:
+		switch {
+		case '0' <= c && c <= '9':
+			return scanContinue
+		case c == 'e':
+			return s.valueDone()
+		}
+		return s.error(c, "integer")
+
+	case stateStrLen:
+		switch {
+		case '0' <= c && c <= '9':
+			if s.strlen > (math.MaxInt64-9)/10 {
+				s.err = &SyntaxError{"string length too large", s.off - 1}
+				return scanError
+			}
+			s.strlen = s.strlen*10 + int64(c-'0')
+			return scanContinue
+		case c == ':':
+			if s.strlen == 0 {
+				return s.valueDone()
+			}
+			s.state = stateStrBody
+			return scanContinue
+		}
+		return s.error(c, "string length")
+
+	case stateStrBody:
+		s.strlen--
+		if s.strlen == 0 {
+			return s.valueDone()
+		}
+		return scanContinue
+	}
+
+	return s.error(c, "end of input")
+}
+
+// skip consumes n bytes of a string body at once. n must not be larger than
+// s.strlen and the scanner must be in stateStrBody.
+func (s *scanner) skip(n int64) int {
+	s.off += n
+	s.strlen -= n
+	if s.strlen == 0 {
+		return s.valueDone()
+	}
+	return scanContinue
+}
+
+// strLength returns the length of the string being scanned, or 0 if the
+// scanner is not inside one.
+func (s *scanner) strLength() int64 {
+	switch s.state {
+	case stateStrLen, stateStrBody:
+		return s.strlen
+	}
+	return 0
+}
+
+// eof returns the error for input that ends before the value is complete.
+func (s *scanner) eof() error {
+	if s.state == stateDone {
+		return nil
+	}
+	return &SyntaxError{"unexpected end of input", s.off}
+}
+
+func (s *scanner) error(c byte, context string) int {
+	s.err = &SyntaxError{fmt.Sprintf("invalid character %q looking for %s", c, context), s.off - 1}
+	return scanError
+}
+
+func (s *scanner) isDict() bool {
+	if s.depth > 64 {
+		return s.deep[s.depth-65]
+	}
+	return s.dicts&(1<<uint(s.depth-1)) != 0
+}
+
+func (s *scanner) push(dict bool) {
+	s.depth++
+	switch {
+	case s.depth > 64:
+		s.deep = append(s.deep, dict)
+	case dict:
+		s.dicts |= 1 << uint(s.depth-1)
+	default:
+		s.dicts &^= 1 << uint(s.depth-1)
+	}
+}
+
+func (s *scanner) pop() int {
+	if s.depth > 64 {
+		s.deep = s.deep[:len(s.deep)-1]
+	}
+	s.depth--
+	return s.valueDone()
+}
+
+// valueDone moves to the state following a completed value.
+func (s *scanner) valueDone() int {
+	if s.depth == 0 {
+		s.state = stateDone
+		return scanEnd
+	}
+	if s.isDict() && !s.key {
+		s.state = stateKey
+	} else {
+		s.state = stateValue
+	}
+	s.key = false
+	return scanContinue
+}
+
+// scanValue returns the offset just past the bencode value that starts at
+// data[off].
+func scanValue(s *scanner, data []byte, off int) (int, error) {
+	s.reset(int64(off))
+	for i := off; i < len(data); {
+		if s.state == stateStrBody {
+			n := s.strlen
+			if rem := int64(len(data) - i); n > rem {
+				n = rem
+			}
+			i += int(n)
+			if s.skip(n) == scanEnd {
+				return i, nil
+			}
+			continue
+		}
+
+		switch s.step(data[i]) {
+		case scanEnd:
+			return i + 1, nil
+		case scanError:
+			return 0, s.err
+		}
+		i++
+	}
+	return 0, s.eof()
+}
+
+// Valid reports whether data holds exactly one well-formed bencode value:
+// one that the Decoder can parse, with nothing after it.
+func Valid(data []byte) bool {
+	return checkValid(data) == nil
+}
+
+// checkValid returns an error if data is not exactly one bencode value.
+func checkValid(data []byte) error {
+	var s scanner
+	end, err := scanValue(&s, data, 0)
+	if err != nil {
+		return err
+	}
+	if end != len(data) {
+		return &SyntaxError{"trailing data after value", int64(end)}
+	}
+	return nil
+}
+
+// isDigits reports whether b is a non-empty run of decimal digits. The
+// Decoder uses it to accept exactly the integers and string lengths that the
+// scanner does.
+func isDigits(b []byte) bool {
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return len(b) > 0
+}
+
+// isIntText reports whether b is the text of a bencode integer, between the
+// 'i' and the 'e'.
+func isIntText(b []byte) bool {
+	if len(b) > 0 && b[0] == '-' {
+		b = b[1:]
+	}
+	return isDigits(b)
+}
@@ -0,0 +1,106 @@
+package bencode
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCheckValid(t *testing.T) {
+	type testCase struct {
+		in    string
+		valid bool
+	}
+
+	var cases = []testCase{
+		{`i5e`, true},
+		{`i-5e`, true},
+		{`i007e`, true},
+		{`i-0e`, true},
+		{`0:`, true},
+		{`5:hello`, true},
+		{`le`, true},
+		{`de`, true},
+		{`li1e3:fooe`, true},
+		{`d1:ai1e1:bl1:cee`, true},
+		{`d1:bi1e1:ai2ee`, true},
+		{strings.Repeat("l", 100) + strings.Repeat("e", 100), true},
+		{strings.Repeat("ld1:a", 50) + "i1e" + strings.Repeat("ee", 50), true},
+
+		{``, false},
+		{`e`, false},
+		{`ie`, false},
+		{`i-e`, false},
+		{`i+5e`, false},
+		{`i5`, false},
+		{`i1.5e`, false},
+		{`5:hell`, false},
+		{`-1:a`, false},
+		{`1a:b`, false},
+		{`l`, false},
+		{`li1e`, false},
+		{`d1:ae`, false},
+		{`di1ei2ee`, false},
+		{`dlee1:ae`, false},
+		{`i1ei2e`, false},
+		{`3:foox`, false},
+		{`lee`, false},
+		{`99999999999999999999:a`, false},
+		{strings.Repeat("l", 100) + strings.Repeat("e", 99), false},
+	}
+
+	for i, tt := range cases {
+		err := checkValid([]byte(tt.in))
+		if Valid([]byte(tt.in)) != (err == nil) {
+			t.Errorf("#%d (%q): Valid disagrees with checkValid", i, tt.in)
+		}
+		if tt.valid && err != nil {
+			t.Errorf("#%d (%q): Unexpected err: %v", i, tt.in, err)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("#%d (%q): Expected err is nil", i, tt.in)
+		}
+	}
+}
+
+func TestValidAllocs(t *testing.T) {
+	data := []byte(strings.Repeat("ld1:a", 20) + "i1e" + strings.Repeat("ee", 20))
+	allocs := testing.AllocsPerRun(100, func() {
+		if !Valid(data) {
+			t.Fatal("not valid")
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("Valid allocated %v times", allocs)
+	}
+}
+
+func TestDecoderRawScan(t *testing.T) {
+	// the decoder finds the end of each raw value with the scanner,
+	// leaving the rest of the stream for later calls.
+	big := strings.Repeat("x", 200000)
+	in := "li1ee" + "200000:" + big + "d1:ai1ee"
+	dec := NewDecoder(strings.NewReader(in))
+
+	var raws []RawMessage
+	for i := 0; i < 3; i++ {
+		var raw RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			t.Fatal(err)
+		}
+		raws = append(raws, raw)
+	}
+	if got := bytes.Join([][]byte{raws[0], raws[1], raws[2]}, nil); string(got) != in {
+		t.Errorf("raw values do not cover the input")
+	}
+	if dec.BytesParsed() != len(in) {
+		t.Errorf("BytesParsed = %d, want %d", dec.BytesParsed(), len(in))
+	}
+
+	for i, in := range []string{`li5e`, `d1:ae`, `i5`, `di1ei2ee`, `5:abc`, `i-e`, `dl`} {
+		var raw RawMessage
+		if err := DecodeString(in, &raw); err == nil {
+			t.Errorf("#%d (%q): Expected err is nil", i, in)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package bencode
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+var (
+	reflectReaderStringType = reflect.TypeOf(ReaderString{})
+	reflectWriterStringType = reflect.TypeOf(WriterString{})
+)
+
+// ReaderString is a bencode string whose contents are copied from a reader
+// when encoding, so that large strings need not be held in memory.
+// Exactly Len bytes are read from R; encoding fails if R ends early or has
+// more data after them.
+type ReaderString struct {
+	Len int64
+	R   io.Reader
+}
+
+func (e *Encoder) encodeReaderString(rs ReaderString) error {
+	if rs.Len < 0 {
+		return fmt.Errorf("invalid negative ReaderString length: %d", rs.Len)
+	}
+	if rs.R == nil {
+		return errors.New("ReaderString has a nil reader")
+	}
+
+	if _, err := fmt.Fprintf(e.w, "%d:", rs.Len); err != nil {
+		return err
+	}
+	n, err := io.CopyN(e.w, rs.R, rs.Len)
+	if err == io.EOF {
+		return fmt.Errorf("ReaderString ended after %d of %d bytes", n, rs.Len)
+	}
+	if err != nil {
+		return err
+	}
+
+	// the reader must be exhausted now
+	var extra [1]byte
+	switch _, err := io.ReadFull(rs.R, extra[:]); err {
+	case io.EOF:
+		return nil
+	case nil:
+		return fmt.Errorf("ReaderString has more than %d bytes", rs.Len)
+	default:
+		return err
+	}
+}
+
+// WriterString is a bencode string whose contents are written to a writer
+// when decoding, so that large strings need not be held in memory.
+// Set W before decoding; the contents are copied to it in chunks as they
+// are read and N is set to their length. If W is nil the contents are
+// discarded.
+type WriterString struct {
+	W io.Writer
+	N int64
+}
+
+func (d *Decoder) decodeWriterString(ws *WriterString, l int64) error {
+	w := ws.W
+	if w == nil {
+		w = io.Discard
+	}
+
+	n, err := io.CopyN(w, d.r, l)
+	d.n += int(n)
+	ws.N = n
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,107 @@
+package bencode
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestEncodeReaderString(t *testing.T) {
+	type encodeTestCase struct {
+		in  interface{}
+		out string
+		err bool
+	}
+
+	type file struct {
+		Name    string       `bencode:"name"`
+		Content ReaderString `bencode:"content"`
+	}
+
+	var encodeCases = []encodeTestCase{
+		{ReaderString{5, strings.NewReader("hello")}, `5:hello`, false},
+		{ReaderString{0, strings.NewReader("")}, `0:`, false},
+		{&ReaderString{3, strings.NewReader("foo")}, `3:foo`, false},
+		{file{"a", ReaderString{3, strings.NewReader("abc")}}, `d7:content3:abc4:name1:ae`, false},
+		{[]interface{}{ReaderString{1, strings.NewReader("x")}, 1}, `l1:xi1ee`, false},
+		{ReaderString{1 << 16, strings.NewReader(strings.Repeat("z", 1<<16))},
+			"65536:" + strings.Repeat("z", 1<<16), false},
+
+		// reader ends early, has more data, or fails
+		{ReaderString{6, strings.NewReader("hello")}, ``, true},
+		{ReaderString{4, strings.NewReader("hello")}, ``, true},
+		{ReaderString{1, io.MultiReader(strings.NewReader("h"), errReader{})}, ``, true},
+		{ReaderString{-1, strings.NewReader("")}, ``, true},
+		{ReaderString{1, nil}, ``, true},
+	}
+
+	for i, tt := range encodeCases {
+		data, err := EncodeString(tt.in)
+		if !tt.err && err != nil {
+			t.Errorf("#%d: Unexpected err: %v", i, err)
+			continue
+		}
+		if tt.err && err == nil {
+			t.Errorf("#%d: Expected err is nil", i)
+			continue
+		}
+		if !tt.err && tt.out != data {
+			t.Errorf("#%d: Val: %q != %q", i, data, tt.out)
+		}
+	}
+}
+
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) { return 0, errors.New("oops") }
+
+func TestDecodeWriterString(t *testing.T) {
+	type archive struct {
+		Name string       `bencode:"name"`
+		Blob WriterString `bencode:"blob"`
+		Size int          `bencode:"size"`
+	}
+
+	var blob strings.Builder
+	var a archive
+	a.Blob.W = &blob
+	big := strings.Repeat("x", 100000)
+	in := "d4:blob100000:" + big + "4:name3:foo4:sizei7ee"
+	if err := DecodeString(in, &a); err != nil {
+		t.Fatal(err)
+	}
+	if blob.String() != big || a.Blob.N != int64(len(big)) {
+		t.Errorf("blob: got %d bytes, N = %d", blob.Len(), a.Blob.N)
+	}
+	if a.Name != "foo" || a.Size != 7 {
+		t.Errorf("unexpected fields: %+v", a)
+	}
+
+	// a nil writer discards the contents
+	var ws WriterString
+	if err := DecodeString("5:hello", &ws); err != nil {
+		t.Fatal(err)
+	}
+	if ws.N != 5 {
+		t.Errorf("N = %d, want 5", ws.N)
+	}
+
+	// inside raw messages the contents are kept as usual
+	var raw struct {
+		Blob RawMessage `bencode:"blob"`
+	}
+	if err := DecodeString("d4:blob3:abce", &raw); err != nil {
+		t.Fatal(err)
+	}
+	if string(raw.Blob) != "3:abc" {
+		t.Errorf("raw: got %q", raw.Blob)
+	}
+
+	for i, in := range []string{`5:hell`, `i5e`, `le`, `-1:`, `de`, `d1:Ni5ee`} {
+		var ws WriterString
+		if err := DecodeString(in, &ws); err == nil {
+			t.Errorf("#%d (%v): Expected err is nil", i, in)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package bencode
 import (
 	"reflect"
 	"strings"
+	"time"
 	"unicode"
 )
 
@@ -38,6 +39,23 @@ func (options tagOptions) Contains(optionName string) bool {
 	return false
 }
 
+// elemOptions returns the tag options for the elements of a list or the
+// values of a map from the options of the field holding it, so that a
+// ,tuple field may be a slice or map of structs, and the time options apply
+// to slices and maps of times and durations.
+func elemOptions(opts tagOptions) tagOptions {
+	var elem tagOptions
+	for _, name := range [...]string{"tuple", "unix", "unixmilli", "rfc3339"} {
+		if opts.Contains(name) {
+			if elem != "" {
+				elem += ","
+			}
+			elem += tagOptions(name)
+		}
+	}
+	return elem
+}
+
 func isValidTag(key string) bool {
 	if key == "" {
 		return false
@@ -70,6 +88,13 @@ func isEmptyValue(v reflect.Value) bool {
 		return v.Float() == 0
 	case reflect.Interface, reflect.Ptr:
 		return v.IsNil()
+	case reflect.Struct:
+		switch v.Type() {
+		case reflectTimeType:
+			return v.Interface().(time.Time).IsZero()
+		case reflectAddrPortType, reflectNodeType:
+			return v.IsZero()
+		}
 	}
 
 	return false
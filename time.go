@@ -0,0 +1,135 @@
+package bencode
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+var (
+	reflectTimeType     = reflect.TypeOf(time.Time{})
+	reflectDurationType = reflect.TypeOf(time.Duration(0))
+)
+
+// encodeTime writes a time.Time as the options of its field tag ask:
+//
+//	,unix       an integer number of seconds since the Unix epoch
+//	,unixmilli  an integer number of milliseconds since the Unix epoch
+//	,rfc3339    a string in RFC 3339 format with nanoseconds
+//
+// Precision finer than the unit is truncated. Without an option the time is
+// written as with ,rfc3339, as its MarshalText method would.
+func (e *Encoder) encodeTime(t time.Time, opts tagOptions) error {
+	var err error
+	switch {
+	case opts.Contains("unixmilli"):
+		_, err = fmt.Fprintf(e.w, "i%de", t.UnixMilli())
+	case opts.Contains("unix"):
+		_, err = fmt.Fprintf(e.w, "i%de", t.Unix())
+	default:
+		s := t.Format(time.RFC3339Nano)
+		_, err = fmt.Fprintf(e.w, "%d:%s", len(s), s)
+	}
+	return err
+}
+
+// encodeDuration writes a time.Duration as an integer number of seconds for
+// the ,unix option or of milliseconds for ,unixmilli. It returns false if
+// neither is set, so that the nanoseconds are written as usual.
+func (e *Encoder) encodeDuration(d time.Duration, opts tagOptions) (bool, error) {
+	var err error
+	switch {
+	case opts.Contains("unixmilli"):
+		_, err = fmt.Fprintf(e.w, "i%de", d.Milliseconds())
+	case opts.Contains("unix"):
+		_, err = fmt.Fprintf(e.w, "i%de", int64(d/time.Second))
+	default:
+		return false, nil
+	}
+	return true, err
+}
+
+// decodeTime reads a time.Time written with the same options as encodeTime,
+// in UTC. Without an option either an integer number of seconds or an
+// RFC 3339 string is accepted.
+func (d *Decoder) decodeTime(v reflect.Value, opts tagOptions) error {
+	next, err := d.peekByte()
+	if err != nil {
+		return err
+	}
+
+	var (
+		unix   = opts.Contains("unix")
+		milli  = opts.Contains("unixmilli")
+		rfc    = opts.Contains("rfc3339")
+		anyFmt = !unix && !milli && !rfc
+	)
+
+	switch {
+	case next == 'i' && (unix || milli || anyFmt):
+		var n int64
+		if err := d.decodeInt(reflect.ValueOf(&n).Elem()); err != nil {
+			return err
+		}
+		if milli {
+			v.Set(reflect.ValueOf(time.UnixMilli(n).UTC()))
+		} else {
+			v.Set(reflect.ValueOf(time.Unix(n, 0).UTC()))
+		}
+		return nil
+
+	case '0' <= next && next <= '9' && (rfc || anyFmt):
+		var s string
+		if err := d.decodeString(reflect.ValueOf(&s).Elem()); err != nil {
+			return err
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	return fmt.Errorf("Cannot store %s into time.Time with options %q", describeNext(next), opts)
+}
+
+// decodeDuration reads a time.Duration written with the ,unix or ,unixmilli
+// option. It returns false if neither is set, so that the nanoseconds are
+// read as usual.
+func (d *Decoder) decodeDuration(v reflect.Value, opts tagOptions) (bool, error) {
+	unit := time.Second
+	switch {
+	case opts.Contains("unixmilli"):
+		unit = time.Millisecond
+	case opts.Contains("unix"):
+	default:
+		return false, nil
+	}
+
+	var n int64
+	if err := d.decodeInto(reflect.ValueOf(&n).Elem(), ""); err != nil {
+		return true, err
+	}
+	if n > int64(1<<63-1)/int64(unit) || n < -int64(1<<63-1)/int64(unit) {
+		return true, fmt.Errorf("duration %d overflows time.Duration", n)
+	}
+	v.SetInt(n * int64(unit))
+	return true, nil
+}
+
+// describeNext names the kind of bencode value that starts with c.
+func describeNext(c byte) string {
+	switch {
+	case c == 'i':
+		return "int64"
+	case c == 'l':
+		return "list"
+	case c == 'd':
+		return "dictionary"
+	case '0' <= c && c <= '9':
+		return "string"
+	}
+	return "invalid input " + strconv.QuoteRune(rune(c))
+}
@@ -0,0 +1,159 @@
+package bencode
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEncodeTime(t *testing.T) {
+	when := time.Date(2020, 1, 2, 3, 4, 5, 6789000, time.UTC)
+
+	type testCase struct {
+		val    interface{}
+		expect string
+	}
+
+	var cases = []testCase{
+		{when, `27:2020-01-02T03:04:05.006789Z`},
+		{&when, `27:2020-01-02T03:04:05.006789Z`},
+		{[]time.Time{when}, `l27:2020-01-02T03:04:05.006789Ze`},
+		{struct {
+			T time.Time `bencode:"creation date,unix"`
+		}{when}, `d13:creation datei1577934245ee`},
+		{struct {
+			T time.Time `bencode:"t"`
+		}{when.In(time.FixedZone("", 3600))}, `d1:t32:2020-01-02T04:04:05.006789+01:00e`},
+		{struct {
+			T time.Time `bencode:"t,unix"`
+		}{when}, `d1:ti1577934245ee`},
+		{struct {
+			T time.Time `bencode:"t,unixmilli"`
+		}{when}, `d1:ti1577934245006ee`},
+		{struct {
+			T *time.Time `bencode:"t,rfc3339"`
+		}{&when}, `d1:t27:2020-01-02T03:04:05.006789Ze`},
+		{struct {
+			T time.Time `bencode:"t,omitempty"`
+		}{}, `de`},
+
+		{time.Duration(1500) * time.Millisecond, `i1500000000e`},
+		{struct {
+			D time.Duration `bencode:"d,unix"`
+		}{1500 * time.Millisecond}, `d1:di1ee`},
+		{struct {
+			D time.Duration `bencode:"d,unixmilli"`
+		}{1500 * time.Millisecond}, `d1:di1500ee`},
+	}
+
+	for i, tt := range cases {
+		got, err := EncodeString(tt.val)
+		if err != nil {
+			t.Errorf("#%d: Unexpected err: %v", i, err)
+			continue
+		}
+		if got != tt.expect {
+			t.Errorf("#%d: Val: %q != %q", i, got, tt.expect)
+		}
+	}
+}
+
+func TestDecodeTime(t *testing.T) {
+	type plain struct {
+		T time.Time `bencode:"t"`
+	}
+	type unix struct {
+		T time.Time `bencode:"t,unix"`
+	}
+	type milli struct {
+		T *time.Time `bencode:"t,unixmilli"`
+	}
+	type rfc struct {
+		T time.Time `bencode:"t,rfc3339"`
+	}
+	type dur struct {
+		S time.Duration `bencode:"s,unix"`
+		M time.Duration `bencode:"m,unixmilli"`
+		N time.Duration `bencode:"n"`
+	}
+
+	sec := time.Unix(1577934245, 0).UTC()
+	ms := time.UnixMilli(1577934245006).UTC()
+
+	type testCase struct {
+		in     string
+		val    interface{}
+		expect func(interface{}) bool
+		err    bool
+	}
+
+	var cases = []testCase{
+		{`d1:ti1577934245ee`, new(plain), func(v interface{}) bool { return v.(*plain).T.Equal(sec) }, false},
+		{`d1:t20:2020-01-02T03:04:05Ze`, new(plain), func(v interface{}) bool { return v.(*plain).T.Equal(sec) }, false},
+		{`d1:ti1577934245ee`, new(unix), func(v interface{}) bool { return v.(*unix).T.Equal(sec) }, false},
+		{`d1:ti1577934245006ee`, new(milli), func(v interface{}) bool { return v.(*milli).T.Equal(ms) }, false},
+		{`d1:t24:2020-01-02T03:04:05.006Ze`, new(rfc), func(v interface{}) bool { return v.(*rfc).T.Equal(ms) }, false},
+		{`d1:si2e1:mi1500e1:ni7ee`, new(dur), func(v interface{}) bool {
+			d := v.(*dur)
+			return d.S == 2*time.Second && d.M == 1500*time.Millisecond && d.N == 7
+		}, false},
+		{`i1577934245e`, new(time.Time), func(v interface{}) bool { return v.(*time.Time).Equal(sec) }, false},
+
+		{`d1:t3:fooe`, new(plain), nil, true},
+		{`d1:t20:2020-01-02T03:04:05Ze`, new(unix), nil, true},
+		{`d1:ti1ee`, new(rfc), nil, true},
+		{`d1:tlee`, new(plain), nil, true},
+		{`d1:si9223372036854775807ee`, new(dur), nil, true},
+	}
+
+	for i, tt := range cases {
+		err := DecodeString(tt.in, tt.val)
+		if !tt.err && err != nil {
+			t.Errorf("#%d: Unexpected err: %v", i, err)
+			continue
+		}
+		if tt.err && err == nil {
+			t.Errorf("#%d: Expected err is nil", i)
+			continue
+		}
+		if !tt.err && !tt.expect(tt.val) {
+			t.Errorf("#%d: Val: %+v", i, tt.val)
+		}
+	}
+}
+
+func TestTimeContainers(t *testing.T) {
+	when := time.Date(2023, 11, 14, 22, 13, 20, 123456789, time.UTC)
+
+	type times struct {
+		Many  []time.Time              `bencode:"many,unixmilli"`
+		Names map[string]time.Time     `bencode:"names,rfc3339"`
+		Waits map[string]time.Duration `bencode:"waits,unixmilli"`
+		Secs  [][]time.Duration        `bencode:"secs,unix"`
+	}
+
+	val := times{
+		Many:  []time.Time{when},
+		Names: map[string]time.Time{"a": when},
+		Waits: map[string]time.Duration{"b": 1500 * time.Millisecond},
+		Secs:  [][]time.Duration{{2 * time.Second}},
+	}
+	const expect = `d4:manyli1700000000123ee` +
+		`5:namesd1:a30:2023-11-14T22:13:20.123456789Ze` +
+		`4:secslli2eee` +
+		`5:waitsd1:bi1500eee`
+
+	got, err := EncodeString(val)
+	if err != nil || got != expect {
+		t.Fatalf("got %q, %v", got, err)
+	}
+
+	var back times
+	if err := DecodeString(got, &back); err != nil {
+		t.Fatal(err)
+	}
+	if len(back.Many) != 1 || !back.Many[0].Equal(when.Truncate(time.Millisecond)) ||
+		!back.Names["a"].Equal(when) || back.Waits["b"] != 1500*time.Millisecond ||
+		back.Secs[0][0] != 2*time.Second {
+		t.Errorf("got %+v", back)
+	}
+}
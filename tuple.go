@@ -0,0 +1,82 @@
+package bencode
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// tupleField is a struct field written at a fixed position of a tuple.
+type tupleField struct {
+	index []int
+	name  string
+	opts  tagOptions
+}
+
+// tupleFields returns the fields of the struct type t in the order they
+// are written with the ,tuple tag option: the exported fields in declaration
+// order, leaving out fields tagged "-" and putting the fields of untagged
+// embedded structs in place of them. The omitempty option does not apply.
+func tupleFields(t reflect.Type) []tupleField {
+	var fields []tupleField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		tag := f.Tag.Get("bencode")
+		if tag == "-" {
+			continue
+		}
+		if f.Anonymous && f.Type.Kind() == reflect.Struct && tag == "" {
+			for _, sub := range tupleFields(f.Type) {
+				sub.index = append([]int{i}, sub.index...)
+				fields = append(fields, sub)
+			}
+			continue
+		}
+		_, opts := parseTag(tag)
+		fields = append(fields, tupleField{[]int{i}, f.Name, opts})
+	}
+	return fields
+}
+
+// encodeTuple writes the fields of the struct v as a list.
+func (e *Encoder) encodeTuple(v reflect.Value) error {
+	if _, err := fmt.Fprint(e.w, "l"); err != nil {
+		return err
+	}
+	for _, f := range tupleFields(v.Type()) {
+		fv := v.FieldByIndex(f.index)
+		// a left out value would move the fields after it
+		if isNilValue(fv) {
+			return fmt.Errorf("Can't encode nil field %s of tuple %s", f.name, v.Type())
+		}
+		if err := e.encodeValue(fv, f.opts); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprint(e.w, "e")
+	return err
+}
+
+// decodeTuple reads a list into the fields of the struct v, which must
+// have one element for each field.
+func (d *Decoder) decodeTuple(v reflect.Value) error {
+	fields := tupleFields(v.Type())
+	i := 0
+	err := d.decodeElements(func() (bool, error) {
+		if i == len(fields) {
+			return false, fmt.Errorf("Can't decode a list of more than %d elements into tuple %s", len(fields), v.Type())
+		}
+		err := d.decodeInto(v.FieldByIndex(fields[i].index), fields[i].opts)
+		i++
+		return true, err
+	})
+	if err != nil {
+		return err
+	}
+	if i < len(fields) {
+		return fmt.Errorf("Can't decode a list of %d elements into tuple %s of %d fields", i, v.Type(), len(fields))
+	}
+	return nil
+}
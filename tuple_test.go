@@ -0,0 +1,128 @@
+package bencode
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type tupleHostPort struct {
+	Host string
+	Port int
+}
+
+// TupleBase is exported so that its fields are seen when embedded.
+type TupleBase struct {
+	Host string
+	Port int
+}
+
+type tupleRecord struct {
+	TupleBase
+	Tags    []string       `bencode:"tags"`
+	Seen    time.Time      `bencode:",unixmilli"`
+	Skipped string         `bencode:"-"`
+	Inner   *tupleHostPort `bencode:",tuple"`
+
+	hidden int
+}
+
+func TestTupleRoundTrip(t *testing.T) {
+	type torrent struct {
+		Nodes  []tupleHostPort           `bencode:"nodes,tuple"`
+		Byname map[string]*tupleHostPort `bencode:"byname,tuple,omitempty"`
+		Record tupleRecord               `bencode:"record,tuple"`
+		Plain  tupleHostPort             `bencode:"plain"`
+		Nested [][]tupleHostPort         `bencode:"nested,tuple,omitempty"`
+	}
+
+	type testCase struct {
+		val    torrent
+		expect string
+	}
+
+	var cases = []testCase{
+		{torrent{
+			Nodes:  []tupleHostPort{{"router.example", 6881}, {"1.2.3.4", 1}},
+			Record: tupleRecord{TupleBase{"h", 2}, []string{"a"}, time.UnixMilli(1500).UTC(), "", &tupleHostPort{"i", 3}, 0},
+			Plain:  tupleHostPort{"p", 4},
+		}, "d5:nodesl l14:router.examplei6881ee l7:1.2.3.4i1ee e" +
+			"5:plaind4:Host1:p4:Porti4ee" +
+			"6:recordl 1:hi2e l1:ae i1500e l1:ii3ee ee"},
+		{torrent{
+			Byname: map[string]*tupleHostPort{"x": {"x", 5}, "nil": nil},
+			Record: tupleRecord{Seen: time.UnixMilli(0).UTC(), Inner: &tupleHostPort{}},
+			Nested: [][]tupleHostPort{{{"n", 6}}},
+		}, "d6:bynamed1:xl1:xi5eee" +
+			"6:nestedlll1:ni6eeee" +
+			"5:nodesle" +
+			"5:plaind4:Host0:4:Porti0ee" +
+			"6:recordl0:i0elei0el0:i0eeee"},
+	}
+
+	for i, tt := range cases {
+		expect := removeSpaces(tt.expect)
+		got, err := EncodeString(tt.val)
+		if err != nil {
+			t.Errorf("#%d: Unexpected err: %v", i, err)
+			continue
+		}
+		if got != expect {
+			t.Errorf("#%d: Val: %q != %q", i, got, expect)
+			continue
+		}
+
+		var back torrent
+		if err := DecodeString(got, &back); err != nil {
+			t.Errorf("#%d: Unexpected err: %v", i, err)
+			continue
+		}
+		delete(tt.val.Byname, "nil")
+		if !reflect.DeepEqual(back, tt.val) {
+			t.Errorf("#%d: Val: %#v != %#v", i, back, tt.val)
+		}
+	}
+}
+
+// removeSpaces drops the spaces that separate the elements of the
+// expected tuples for readability.
+func removeSpaces(s string) string {
+	out := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] != ' ' {
+			out = append(out, s[i])
+		}
+	}
+	return string(out)
+}
+
+func TestTupleErrors(t *testing.T) {
+	type pair struct {
+		P tupleHostPort `bencode:"p,tuple"`
+	}
+	type ptrs struct {
+		P struct {
+			A *int
+			B int
+		} `bencode:"p,tuple"`
+	}
+
+	// a nil field would move the fields after it
+	if _, err := EncodeString(ptrs{}); err == nil {
+		t.Errorf("Expected err is nil")
+	}
+
+	var decodes = []string{
+		`d1:pl1:aee`,
+		`d1:pl1:ai1ei2eee`,
+		`d1:pd4:Host1:a4:Porti1eee`,
+		`d1:pli1e1:aee`,
+		`d1:p1:ae`,
+	}
+	for i, in := range decodes {
+		var p pair
+		if err := DecodeString(in, &p); err == nil {
+			t.Errorf("#%d: Expected err is nil", i)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package bencode
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"reflect"
+)
+
+// A Union describes the concrete types that values of an interface type
+// may hold, told apart by the string value of a key in their dictionaries,
+// such as the "y" key of KRPC messages. Types maps each value of the key to
+// a type, which must implement the interface and encode as a dictionary.
+//
+// When decoding into a value of the interface type, whether a struct field,
+// a list element, a map value or the value pointed to by the argument of
+// Decode, the key is looked up first and the dictionary is decoded into a
+// new value of the matching type. When encoding a value held in a variable of
+// the interface type, the key is written into its dictionary. A value
+// passed directly to Encode is not held in a variable of the interface
+// type, so pass a pointer to the variable instead.
+type Union struct {
+	Key   string
+	Types map[string]reflect.Type
+}
+
+// union is a registered Union with the reverse of its mapping.
+type union struct {
+	Union
+	names map[reflect.Type]string
+}
+
+// RegisterUnion sets the Union used for values of the interface type iface,
+// replacing any that was registered before. It panics if iface is not an
+// interface type or one of the types does not implement it.
+func (r *Registry) RegisterUnion(iface reflect.Type, u Union) {
+	if iface.Kind() != reflect.Interface {
+		panic(fmt.Sprintf("bencode: RegisterUnion of non-interface type %s", iface))
+	}
+
+	un := &union{
+		Union: Union{Key: u.Key, Types: make(map[string]reflect.Type, len(u.Types))},
+		names: make(map[reflect.Type]string, len(u.Types)),
+	}
+	for name, t := range u.Types {
+		if !t.Implements(iface) {
+			panic(fmt.Sprintf("bencode: RegisterUnion type %s does not implement %s", t, iface))
+		}
+		un.Types[name] = t
+		un.names[t] = name
+	}
+
+	r.update(func(state *registryState) { state.unions[iface] = un })
+}
+
+// RegisterUnion sets the Union used for values of the interface type iface
+// by every Encoder and Decoder, unless the Registry of their Options has one
+// for iface.
+func RegisterUnion(iface reflect.Type, u Union) {
+	globalRegistry.RegisterUnion(iface, u)
+}
+
+func (r *Registry) lookupUnion(t reflect.Type) *union {
+	if r == nil {
+		return nil
+	}
+	state := r.state.Load()
+	if state == nil {
+		return nil
+	}
+	return state.unions[t]
+}
+
+// unionTarget follows the non-nil pointers in v to an interface with a
+// Union in r or the global registry, and returns them, or nil if there is
+// none.
+func unionTarget(r *Registry, v reflect.Value) (*union, reflect.Value) {
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Interface {
+		return nil, v
+	}
+	if u := r.lookupUnion(v.Type()); u != nil {
+		return u, v
+	}
+	return globalRegistry.lookupUnion(v.Type()), v
+}
+
+// encodeUnion writes the value held in the interface v of a Union, with the
+// key of the Union set to the name of its type.
+func (e *Encoder) encodeUnion(u *union, v reflect.Value) error {
+	elem := v.Elem()
+	name, ok := u.names[elem.Type()]
+	if !ok {
+		return fmt.Errorf("Can't encode type %s: not in the union for %s", elem.Type(), v.Type())
+	}
+
+	var buf bytes.Buffer
+	sub := *e
+	sub.w, sub.stack, sub.scratch = &buf, nil, nil
+	if err := sub.encodeValue(elem, ""); err != nil {
+		return err
+	}
+
+	out, err := Pointer{u.Key}.Set(buf.Bytes(), appendString(nil, []byte(name)))
+	if err != nil {
+		return fmt.Errorf("Can't encode type %s in the union for %s: %w", elem.Type(), v.Type(), err)
+	}
+	_, err = e.w.Write(out)
+	return err
+}
+
+// decodeUnion reads a dictionary into a new value of the type of a Union
+// named by its key, and stores it in the interface v.
+func (d *Decoder) decodeUnion(u *union, v reflect.Value) error {
+	raw, err := d.readRaw()
+	if err != nil {
+		return err
+	}
+
+	var name string
+	if err := GetInto(raw, &name, u.Key); err != nil {
+		return fmt.Errorf("Can't decode into %s: %w", v.Type(), err)
+	}
+	t, ok := u.Types[name]
+	if !ok {
+		return fmt.Errorf("Can't decode into %s: unknown %s %q", v.Type(), u.Key, name)
+	}
+	nv := reflect.New(t)
+
+	// the key need not have a field in the type
+	if d.failUnknown {
+		fields := make(map[string]structField)
+		setStructValues(fields, indirect(nv, true))
+		if _, ok := fields[u.Key]; !ok {
+			if raw, err = (Pointer{u.Key}).Delete(raw); err != nil {
+				return err
+			}
+		}
+	}
+
+	// decode the dictionary with the options of d
+	sub := *d
+	sub.src = bytes.NewReader(raw)
+	sub.r = bufio.NewReader(sub.src)
+	sub.scan, sub.buf, sub.n = scanner{}, nil, 0
+	if err := sub.decodeInto(nv, ""); err != nil {
+		return err
+	}
+
+	v.Set(nv.Elem())
+	return nil
+}
@@ -0,0 +1,173 @@
+package bencode
+
+import (
+	"reflect"
+	"testing"
+)
+
+type krpcMessage interface{ transaction() string }
+
+type krpcQuery struct {
+	T string            `bencode:"t"`
+	Q string            `bencode:"q"`
+	A map[string]string `bencode:"a"`
+}
+
+type krpcResponse struct {
+	T string            `bencode:"t"`
+	R map[string]string `bencode:"r"`
+}
+
+type krpcError struct {
+	T string        `bencode:"t"`
+	Y string        `bencode:"y"`
+	E []interface{} `bencode:"e"`
+}
+
+func (q krpcQuery) transaction() string     { return q.T }
+func (r *krpcResponse) transaction() string { return r.T }
+func (e krpcError) transaction() string     { return e.T }
+
+func krpcOptions() Options {
+	r := NewRegistry()
+	r.RegisterUnion(reflect.TypeFor[krpcMessage](), Union{
+		Key: "y",
+		Types: map[string]reflect.Type{
+			"q": reflect.TypeFor[krpcQuery](),
+			"r": reflect.TypeFor[*krpcResponse](),
+			"e": reflect.TypeFor[krpcError](),
+		},
+	})
+	return Options{Registry: r}
+}
+
+func TestUnionDecode(t *testing.T) {
+	opts := krpcOptions()
+
+	type testCase struct {
+		in     string
+		expect krpcMessage
+		err    bool
+	}
+
+	var cases = []testCase{
+		{`d1:ad2:id2:abe1:q4:ping1:t2:aa1:y1:qe`, krpcQuery{T: "aa", Q: "ping", A: map[string]string{"id": "ab"}}, false},
+		{`d1:rd2:id2:cde1:t2:bb1:y1:re`, &krpcResponse{T: "bb", R: map[string]string{"id": "cd"}}, false},
+		{`d1:eli201e4:oopse1:t2:cc1:y1:ee`, krpcError{T: "cc", Y: "e", E: []interface{}{int64(201), "oops"}}, false},
+
+		{`d1:t2:dd1:y1:xe`, nil, true},
+		{`d1:t2:dde`, nil, true},
+		{`d1:t2:dd1:yi1ee`, nil, true},
+		{`li1ee`, nil, true},
+		{`d1:r3:foo1:y1:re`, nil, true},
+	}
+
+	for i, tt := range cases {
+		var msg krpcMessage
+		err := opts.Unmarshal([]byte(tt.in), &msg)
+		if !tt.err && err != nil {
+			t.Errorf("#%d: Unexpected err: %v", i, err)
+			continue
+		}
+		if tt.err && err == nil {
+			t.Errorf("#%d: Expected err is nil", i)
+			continue
+		}
+		if !reflect.DeepEqual(msg, tt.expect) {
+			t.Errorf("#%d: Val: %#v != %#v", i, msg, tt.expect)
+		}
+	}
+
+	// unions are found in fields, list elements and map values
+	var x struct {
+		Msg  krpcMessage            `bencode:"msg"`
+		List []krpcMessage          `bencode:"list"`
+		Map  map[string]krpcMessage `bencode:"map"`
+	}
+	in := `d4:listld1:t1:a1:y1:qed1:t1:b1:y1:ree3:mapd1:xd1:t1:c1:y1:qee3:msgd1:t1:d1:y1:ree`
+	if err := opts.Unmarshal([]byte(in), &x); err != nil {
+		t.Fatal(err)
+	}
+	if x.Msg.transaction() != "d" || len(x.List) != 2 || x.List[1].transaction() != "b" ||
+		x.Map["x"].transaction() != "c" {
+		t.Errorf("got %#v", x)
+	}
+	if _, ok := x.List[1].(*krpcResponse); !ok {
+		t.Errorf("got %T", x.List[1])
+	}
+
+	// without the Registry the interface cannot be decoded into
+	var msg krpcMessage
+	if err := DecodeString(`d1:t2:aa1:y1:qe`, &msg); err == nil {
+		t.Errorf("Expected err is nil")
+	}
+
+	// the key is known even when unknown fields are rejected
+	strict := opts
+	strict.DisallowUnknownFields = true
+	if err := strict.Unmarshal([]byte(`d1:q4:ping1:t2:aa1:y1:qe`), &msg); err != nil {
+		t.Errorf("Unexpected err: %v", err)
+	}
+	if err := strict.Unmarshal([]byte(`d1:t2:aa1:y1:q1:zi1ee`), &msg); err == nil {
+		t.Errorf("Expected err is nil")
+	}
+}
+
+func TestUnionEncode(t *testing.T) {
+	opts := krpcOptions()
+
+	var msg krpcMessage = krpcQuery{T: "aa", Q: "ping"}
+	got, err := opts.Marshal(&msg)
+	if err != nil || string(got) != `d1:ade1:q4:ping1:t2:aa1:y1:qe` {
+		t.Errorf("got %q, %v", got, err)
+	}
+
+	// the Union key replaces a field with the same key
+	msg = krpcError{T: "cc", Y: "wrong"}
+	got, err = opts.Marshal(&msg)
+	if err != nil || string(got) != `d1:ele1:t2:cc1:y1:ee` {
+		t.Errorf("got %q, %v", got, err)
+	}
+
+	list := []krpcMessage{&krpcResponse{T: "bb"}, krpcQuery{T: "aa"}}
+	got, err = opts.Marshal(list)
+	if err != nil || string(got) != `ld1:rde1:t2:bb1:y1:red1:ade1:q0:1:t2:aa1:y1:qee` {
+		t.Errorf("got %q, %v", got, err)
+	}
+
+	// round trip
+	var back []krpcMessage
+	if err := opts.Unmarshal(got, &back); err != nil || !reflect.DeepEqual(back, []krpcMessage{
+		&krpcResponse{T: "bb", R: map[string]string{}},
+		krpcQuery{T: "aa", A: map[string]string{}},
+	}) {
+		t.Errorf("got %#v, %v", back, err)
+	}
+
+	// a type outside the Union cannot be encoded
+	msg = &krpcQuery{T: "aa"}
+	if _, err := opts.Marshal(&msg); err == nil {
+		t.Errorf("Expected err is nil")
+	}
+}
+
+func TestRegisterUnionPanics(t *testing.T) {
+	for i, fn := range []func(){
+		func() { NewRegistry().RegisterUnion(reflect.TypeFor[krpcQuery](), Union{}) },
+		func() {
+			NewRegistry().RegisterUnion(reflect.TypeFor[krpcMessage](), Union{
+				Key:   "y",
+				Types: map[string]reflect.Type{"r": reflect.TypeFor[krpcResponse]()},
+			})
+		},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("#%d: expected a panic", i)
+				}
+			}()
+			fn()
+		}()
+	}
+}
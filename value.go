@@ -0,0 +1,358 @@
+package bencode
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Kind is the type of a bencode value.
+type Kind int
+
+const (
+	InvalidKind Kind = iota
+	IntKind
+	StringKind
+	ListKind
+	DictKind
+)
+
+// String returns the name of the kind.
+func (k Kind) String() string {
+	switch k {
+	case InvalidKind:
+		return "invalid"
+	case IntKind:
+		return "int"
+	case StringKind:
+		return "string"
+	case ListKind:
+		return "list"
+	case DictKind:
+		return "dict"
+	}
+	return "Kind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// Value holds an arbitrary bencode value. Unlike the interface{}
+// representation used by Decode, a Value keeps strings as bytes, keeps
+// dictionary entries in the order they appeared and keeps integers and
+// string lengths exactly as they were written, so any valid input decodes
+// into a Value that encodes back to the same bytes. Input with lists and
+// dictionaries nested more than 65536 deep is rejected.
+//
+// The zero Value is invalid and cannot be encoded.
+type Value struct {
+	kind    Kind
+	raw     []byte // encoding of an integer or string as it was read
+	list    []Value
+	entries []DictEntry
+	keys    [][]byte // encoding of each key written with leading zeros, or nil
+}
+
+// DictEntry is one entry of a dictionary Value.
+type DictEntry struct {
+	Key   string
+	Value Value
+}
+
+// NewInt returns an integer Value.
+func NewInt(n int64) Value {
+	raw := strconv.AppendInt([]byte{'i'}, n, 10)
+	return Value{kind: IntKind, raw: append(raw, 'e')}
+}
+
+// NewString returns a string Value.
+func NewString(s string) Value {
+	return Value{kind: StringKind, raw: appendString(nil, []byte(s))}
+}
+
+// NewBytes returns a string Value holding a copy of b.
+func NewBytes(b []byte) Value {
+	return Value{kind: StringKind, raw: appendString(nil, b)}
+}
+
+// NewList returns a list Value of the given elements.
+func NewList(elems ...Value) Value {
+	return Value{kind: ListKind, list: append([]Value{}, elems...)}
+}
+
+// NewDict returns a dictionary Value of the given entries. The entries are
+// encoded in the order given, so they should be sorted by key to produce
+// canonical bencode.
+func NewDict(entries ...DictEntry) Value {
+	return Value{kind: DictKind, entries: append([]DictEntry{}, entries...)}
+}
+
+// Kind returns the kind of v.
+func (v Value) Kind() Kind {
+	return v.kind
+}
+
+// Int returns the integer held by v. It reports false if v is not an
+// integer or does not fit in an int64.
+func (v Value) Int() (int64, bool) {
+	if v.kind != IntKind {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(string(v.raw[1:len(v.raw)-1]), 10, 64)
+	return n, err == nil
+}
+
+// Bytes returns the contents of v if it is a string, and nil otherwise.
+// The result must not be modified.
+func (v Value) Bytes() []byte {
+	if v.kind != StringKind {
+		return nil
+	}
+	return v.raw[bytes.IndexByte(v.raw, ':')+1:]
+}
+
+// Len returns the length of a string, list or dictionary Value, and 0 for
+// any other kind.
+func (v Value) Len() int {
+	switch v.kind {
+	case StringKind:
+		return len(v.Bytes())
+	case ListKind:
+		return len(v.list)
+	case DictKind:
+		return len(v.entries)
+	}
+	return 0
+}
+
+// Index returns the i'th element of a list Value. It returns an invalid
+// Value if v is not a list or i is out of range.
+func (v Value) Index(i int) Value {
+	if v.kind != ListKind || i < 0 || i >= len(v.list) {
+		return Value{}
+	}
+	return v.list[i]
+}
+
+// List returns the elements of a list Value, and nil for any other kind.
+// The result must not be modified.
+func (v Value) List() []Value {
+	return v.list
+}
+
+// Entries returns the entries of a dictionary Value in order, and nil for
+// any other kind. The result must not be modified.
+func (v Value) Entries() []DictEntry {
+	return v.entries
+}
+
+// Lookup returns the value of key in a dictionary Value. If the key appears
+// more than once the last value is returned, as it would be by Decode.
+func (v Value) Lookup(key string) (Value, bool) {
+	for i := len(v.entries) - 1; i >= 0; i-- {
+		if v.entries[i].Key == key {
+			return v.entries[i].Value, true
+		}
+	}
+	return Value{}, false
+}
+
+// MarshalBencode implements Marshaler.MarshalBencode
+func (v Value) MarshalBencode() ([]byte, error) {
+	return v.appendTo(nil)
+}
+
+func (v Value) appendTo(dst []byte) ([]byte, error) {
+	var err error
+	switch v.kind {
+	case IntKind, StringKind:
+		return append(dst, v.raw...), nil
+
+	case ListKind:
+		dst = append(dst, 'l')
+		for _, elem := range v.list {
+			if dst, err = elem.appendTo(dst); err != nil {
+				return nil, err
+			}
+		}
+		return append(dst, 'e'), nil
+
+	case DictKind:
+		dst = append(dst, 'd')
+		for i, ent := range v.entries {
+			if i < len(v.keys) && v.keys[i] != nil {
+				dst = append(dst, v.keys[i]...)
+			} else {
+				dst = appendString(dst, []byte(ent.Key))
+			}
+			if dst, err = ent.Value.appendTo(dst); err != nil {
+				return nil, err
+			}
+		}
+		return append(dst, 'e'), nil
+	}
+	return nil, errors.New("Can't encode an invalid Value")
+}
+
+// UnmarshalBencode implements Unmarshaler.UnmarshalBencode
+func (v *Value) UnmarshalBencode(b []byte) error {
+	if err := checkValid(b); err != nil {
+		return err
+	}
+	// the parsed value refers into a single copy of the input
+	parsed, _, err := parseValue(append([]byte(nil), b...), 0)
+	if err != nil {
+		return err
+	}
+	*v = parsed
+	return nil
+}
+
+// parseValue parses the valid bencode value at the start of src, inside
+// depth containers, and returns it along with the remainder of src. The
+// Value refers to memory in src.
+func parseValue(src []byte, depth int) (Value, []byte, error) {
+	switch src[0] {
+	case 'i':
+		end := bytes.IndexByte(src, 'e') + 1
+		return Value{kind: IntKind, raw: src[:end:end]}, src[end:], nil
+
+	case 'l':
+		if err := checkDepth(depth + 1); err != nil {
+			return Value{}, nil, err
+		}
+		v := Value{kind: ListKind, list: []Value{}}
+		src = src[1:]
+		for src[0] != 'e' {
+			elem, rest, err := parseValue(src, depth+1)
+			if err != nil {
+				return Value{}, nil, err
+			}
+			v.list = append(v.list, elem)
+			src = rest
+		}
+		return v, src[1:], nil
+
+	case 'd':
+		if err := checkDepth(depth + 1); err != nil {
+			return Value{}, nil, err
+		}
+		v := Value{kind: DictKind, entries: []DictEntry{}}
+		src = src[1:]
+		for src[0] != 'e' {
+			var ent DictEntry
+			key, rest, canonical := canonicalString(src)
+			ent.Key = string(key)
+
+			// keep the spelling of a key length only when it differs from
+			// the one appendString writes
+			if !canonical && v.keys == nil {
+				v.keys = make([][]byte, len(v.entries), cap(v.entries))
+			}
+			if v.keys != nil {
+				var raw []byte
+				if !canonical {
+					end := len(src) - len(rest)
+					raw = src[:end:end]
+				}
+				v.keys = append(v.keys, raw)
+			}
+
+			var err error
+			if ent.Value, src, err = parseValue(rest, depth+1); err != nil {
+				return Value{}, nil, err
+			}
+			v.entries = append(v.entries, ent)
+		}
+		return v, src[1:], nil
+	}
+
+	_, rest := splitString(src)
+	end := len(src) - len(rest)
+	return Value{kind: StringKind, raw: src[:end:end]}, rest, nil
+}
+
+// String returns a readable representation of v, in which strings that are
+// valid UTF-8 are quoted and other strings are written in hexadecimal.
+func (v Value) String() string {
+	var b strings.Builder
+	v.format(&b, false, 0)
+	return b.String()
+}
+
+// Format implements fmt.Formatter. The verbs %v and %s print the same text
+// as String; the %+v verb prints each list element and dictionary entry on
+// its own indented line.
+func (v Value) Format(f fmt.State, verb rune) {
+	switch verb {
+	case 'v', 's':
+		var b strings.Builder
+		v.format(&b, verb == 'v' && f.Flag('+'), 0)
+		io.WriteString(f, b.String())
+	default:
+		fmt.Fprintf(f, "%%!%c(bencode.Value=%s)", verb, v.String())
+	}
+}
+
+// format writes v to b. If pretty is set, each element is written on its own
+// line indented one tab deeper than depth.
+func (v Value) format(b *strings.Builder, pretty bool, depth int) {
+	// item starts the i'th element of a container and end closes it.
+	item := func(i int) {
+		if i > 0 {
+			b.WriteByte(',')
+			if !pretty {
+				b.WriteByte(' ')
+			}
+		}
+		if pretty {
+			b.WriteByte('\n')
+			b.WriteString(strings.Repeat("\t", depth+1))
+		}
+	}
+	end := func(n int, c byte) {
+		if pretty && n > 0 {
+			b.WriteByte('\n')
+			b.WriteString(strings.Repeat("\t", depth))
+		}
+		b.WriteByte(c)
+	}
+
+	switch v.kind {
+	case IntKind:
+		b.Write(v.raw[1 : len(v.raw)-1])
+
+	case StringKind:
+		formatString(b, v.Bytes())
+
+	case ListKind:
+		b.WriteByte('[')
+		for i, elem := range v.list {
+			item(i)
+			elem.format(b, pretty, depth+1)
+		}
+		end(len(v.list), ']')
+
+	case DictKind:
+		b.WriteByte('{')
+		for i, ent := range v.entries {
+			item(i)
+			formatString(b, []byte(ent.Key))
+			b.WriteString(": ")
+			ent.Value.format(b, pretty, depth+1)
+		}
+		end(len(v.entries), '}')
+
+	default:
+		b.WriteString("<invalid>")
+	}
+}
+
+func formatString(b *strings.Builder, s []byte) {
+	if utf8.Valid(s) {
+		b.WriteString(strconv.Quote(string(s)))
+		return
+	}
+	fmt.Fprintf(b, "0x%x", s)
+}
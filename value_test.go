@@ -0,0 +1,199 @@
+package bencode
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValueRoundTrip(t *testing.T) {
+	var cases = []string{
+		`i5e`,
+		`i-5e`,
+		`i007e`,
+		`i-0e`,
+		`i123456789012345678901234567890e`,
+		`0:`,
+		`03:foo`,
+		"4:\x00\xff\x01\x02",
+		`le`,
+		`de`,
+		`li1el3:fooee`,
+		`d1:bi1e1:ai2ee`,
+		`d1:ai1e1:ai2ee`,
+		`d4:infod6:lengthi10e4:name5:a.txtee`,
+		`d03:fooi1ee`,
+		`d1:ai1e01:bi2e1:ci3ee`,
+		`d000:ld02:xyleeee`,
+	}
+
+	for i, in := range cases {
+		var v Value
+		if err := DecodeString(in, &v); err != nil {
+			t.Errorf("#%d (%q): Unexpected err: %v", i, in, err)
+			continue
+		}
+		out, err := EncodeString(v)
+		if err != nil {
+			t.Errorf("#%d (%q): Unexpected err: %v", i, in, err)
+			continue
+		}
+		if out != in {
+			t.Errorf("#%d: Val: %q != %q", i, out, in)
+		}
+	}
+
+	for i, in := range []string{`i5`, `ix5e`, `d1:ae`, `li1e`} {
+		var v Value
+		if err := DecodeBytes([]byte(in), &v); err == nil {
+			t.Errorf("#%d (%q): Expected err is nil", i, in)
+		}
+	}
+}
+
+func TestValueDepth(t *testing.T) {
+	nested := func(n int) []byte {
+		return []byte(strings.Repeat("l", n) + strings.Repeat("e", n))
+	}
+
+	var v Value
+	if err := DecodeBytes(nested(maxParseDepth), &v); err != nil {
+		t.Errorf("Unexpected err: %v", err)
+	}
+	if err := DecodeBytes(nested(maxParseDepth+1), &v); err == nil {
+		t.Errorf("Expected err is nil")
+	}
+
+	var x interface{}
+	opts := Options{InterfaceValues: true}
+	if err := opts.Unmarshal(nested(maxParseDepth+1), &x); err == nil {
+		t.Errorf("Expected err is nil")
+	}
+}
+
+func TestValueAccessors(t *testing.T) {
+	var v Value
+	in := `d1:ai-3e1:bl3:fooi7ee1:c2:hi1:ai4ee`
+	if err := DecodeString(in, &v); err != nil {
+		t.Fatal(err)
+	}
+
+	if v.Kind() != DictKind || v.Len() != 4 {
+		t.Fatalf("kind %v, len %d", v.Kind(), v.Len())
+	}
+	if a, ok := v.Lookup("a"); !ok || a.Kind() != IntKind {
+		t.Errorf("Lookup(a) = %v, %v", a, ok)
+	} else if n, ok := a.Int(); !ok || n != 4 {
+		t.Errorf("Lookup(a).Int() = %d, %v; want the last value 4", n, ok)
+	}
+	if _, ok := v.Lookup("z"); ok {
+		t.Errorf("Lookup(z) found a value")
+	}
+
+	b, _ := v.Lookup("b")
+	if b.Kind() != ListKind || b.Len() != 2 || len(b.List()) != 2 {
+		t.Errorf("b = %v", b)
+	}
+	if s := b.Index(0).Bytes(); string(s) != "foo" {
+		t.Errorf("b[0] = %q", s)
+	}
+	if b.Index(2).Kind() != InvalidKind || b.Index(-1).Kind() != InvalidKind {
+		t.Errorf("out of range Index returned a valid value")
+	}
+	if _, ok := b.Int(); ok {
+		t.Errorf("Int on a list reported ok")
+	}
+	if b.Bytes() != nil {
+		t.Errorf("Bytes on a list returned non-nil")
+	}
+
+	keys := []string{}
+	for _, ent := range v.Entries() {
+		keys = append(keys, ent.Key)
+	}
+	if !reflect.DeepEqual(keys, []string{"a", "b", "c", "a"}) {
+		t.Errorf("keys = %q", keys)
+	}
+
+	var big Value
+	if err := DecodeString(`i99999999999999999999e`, &big); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := big.Int(); ok {
+		t.Errorf("Int on an overflowing integer reported ok")
+	}
+}
+
+func TestValueConstructors(t *testing.T) {
+	type encodeTestCase struct {
+		in  Value
+		out string
+		err bool
+	}
+
+	var encodeCases = []encodeTestCase{
+		{NewInt(-42), `i-42e`, false},
+		{NewString("foo"), `3:foo`, false},
+		{NewBytes([]byte{0, 1}), "2:\x00\x01", false},
+		{NewList(), `le`, false},
+		{NewList(NewInt(1), NewList(NewString(""))), `li1el0:ee`, false},
+		{NewDict(), `de`, false},
+		{NewDict(DictEntry{"z", NewInt(1)}, DictEntry{"a", NewInt(2)}), `d1:zi1e1:ai2ee`, false},
+		{Value{}, ``, true},
+		{NewList(Value{}), ``, true},
+	}
+
+	for i, tt := range encodeCases {
+		data, err := EncodeString(tt.in)
+		if !tt.err && err != nil {
+			t.Errorf("#%d: Unexpected err: %v", i, err)
+			continue
+		}
+		if tt.err && err == nil {
+			t.Errorf("#%d: Expected err is nil", i)
+			continue
+		}
+		if tt.out != data {
+			t.Errorf("#%d: Val: %q != %q", i, data, tt.out)
+		}
+	}
+
+	// values work as struct fields in both directions
+	type torrent struct {
+		Announce string `bencode:"announce"`
+		Info     Value  `bencode:"info"`
+	}
+	var tor torrent
+	in := `d8:announce3:url4:infod4:name1:x6:lengthi1eee`
+	if err := DecodeString(in, &tor); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := EncodeString(tor); err != nil || out != in {
+		t.Errorf("got %q, %v; want %q", out, err, in)
+	}
+}
+
+func TestValueFormat(t *testing.T) {
+	var v Value
+	if err := DecodeString("d1:ai1e1:bl2:hi1:\xffe1:cdee", &v); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := v.String(), `{"a": 1, "b": ["hi", 0xff], "c": {}}`; got != want {
+		t.Errorf("String() = %s, want %s", got, want)
+	}
+	if got, want := fmt.Sprintf("%v|%s", v, v), v.String()+"|"+v.String(); got != want {
+		t.Errorf("%%v|%%s = %s, want %s", got, want)
+	}
+	want := "{\n\t\"a\": 1,\n\t\"b\": [\n\t\t\"hi\",\n\t\t0xff\n\t],\n\t\"c\": {}\n}"
+	if got := fmt.Sprintf("%+v", v); got != want {
+		t.Errorf("%%+v = %s, want %s", got, want)
+	}
+	if got, want := fmt.Sprintf("%d", NewInt(1)), "%!d(bencode.Value=1)"; got != want {
+		t.Errorf("%%d = %s, want %s", got, want)
+	}
+	if got := (Value{}).String(); got != "<invalid>" {
+		t.Errorf("invalid String() = %s", got)
+	}
+}
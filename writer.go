@@ -0,0 +1,179 @@
+package bencode
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// frame is an open list or dictionary in an Encoder.
+type frame struct {
+	dict      bool
+	hasKey    bool   // a key has been written to the dictionary
+	lastKey   string // the last key written to the dictionary
+	wantValue bool   // a key has been written and its value has not
+	single    bool   // the frame holds the one value written by a MarshalerTo
+	written   bool   // the value of a single frame has been written
+}
+
+// BeginList starts a list. Values written until the matching End are its
+// elements.
+func (e *Encoder) BeginList() error {
+	return e.beginContainer('l', false)
+}
+
+// BeginDict starts a dictionary. Until the matching End, each entry is
+// written as a call to Key followed by its value. Keys must be written in
+// strictly increasing order.
+func (e *Encoder) BeginDict() error {
+	return e.beginContainer('d', true)
+}
+
+func (e *Encoder) beginContainer(c byte, dict bool) error {
+	if err := e.beforeValue(); err != nil {
+		return err
+	}
+	if _, err := e.w.Write([]byte{c}); err != nil {
+		return err
+	}
+	e.stack = append(e.stack, frame{dict: dict})
+	return nil
+}
+
+// Key writes the key of the next entry of the innermost dictionary.
+// It must be greater than the previous key written to that dictionary.
+func (e *Encoder) Key(key string) error {
+	if len(e.stack) == 0 || !e.stack[len(e.stack)-1].dict {
+		return errors.New("Key called outside of a dictionary")
+	}
+	f := &e.stack[len(e.stack)-1]
+	if f.wantValue {
+		return fmt.Errorf("Key %q written before the value of key %q", key, f.lastKey)
+	}
+	if f.hasKey && key <= f.lastKey {
+		return fmt.Errorf("unordered dictionary: %q written after %q", key, f.lastKey)
+	}
+	if _, err := fmt.Fprintf(e.w, "%d:%s", len(key), key); err != nil {
+		return err
+	}
+	f.hasKey, f.lastKey, f.wantValue = true, key, true
+	return nil
+}
+
+// End closes the innermost list or dictionary.
+func (e *Encoder) End() error {
+	if len(e.stack) == 0 || e.stack[len(e.stack)-1].single {
+		return errors.New("End called without an open list or dictionary")
+	}
+	if f := e.stack[len(e.stack)-1]; f.wantValue {
+		return fmt.Errorf("End called before the value of key %q", f.lastKey)
+	}
+	if _, err := e.w.Write([]byte{'e'}); err != nil {
+		return err
+	}
+	e.stack = e.stack[:len(e.stack)-1]
+	return nil
+}
+
+// WriteInt writes an integer value.
+func (e *Encoder) WriteInt(n int64) error {
+	if err := e.beforeValue(); err != nil {
+		return err
+	}
+	b := strconv.AppendInt(append(e.scratch[:0], 'i'), n, 10)
+	e.scratch = append(b, 'e')
+	_, err := e.w.Write(e.scratch)
+	return err
+}
+
+// WriteString writes a string value.
+func (e *Encoder) WriteString(s string) error {
+	if err := e.beforeValue(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(e.w, "%d:%s", len(s), s)
+	return err
+}
+
+// WriteValue writes the bencoded data of val as a single value, in the
+// same way as Encode. val must not be nil.
+func (e *Encoder) WriteValue(val interface{}) error {
+	if isNilChain(reflect.ValueOf(val)) {
+		return errors.New("WriteValue called with a nil value")
+	}
+	return e.Encode(val)
+}
+
+// isNilChain reports whether v is nil or a chain of pointers and interfaces
+// ending in nil, for which Encode writes nothing.
+func isNilChain(v reflect.Value) bool {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return true
+		}
+		v = v.Elem()
+	}
+	return !v.IsValid()
+}
+
+// mustWrite reports whether the next value must be written: a dictionary
+// needs a value for its key, and a MarshalerTo must write one.
+func (e *Encoder) mustWrite() bool {
+	if len(e.stack) == 0 {
+		return false
+	}
+	f := e.stack[len(e.stack)-1]
+	return f.dict || f.single
+}
+
+// Close reports an error if a list or dictionary started by BeginList or
+// BeginDict has not been closed by End. It does not close the underlying
+// writer.
+func (e *Encoder) Close() error {
+	if len(e.stack) > 0 {
+		return fmt.Errorf("%d unclosed lists or dictionaries", len(e.stack))
+	}
+	return nil
+}
+
+// beforeValue checks that a value may be written at this point and records
+// that it has been.
+func (e *Encoder) beforeValue() error {
+	if len(e.stack) == 0 {
+		return nil
+	}
+	f := &e.stack[len(e.stack)-1]
+	if f.single {
+		if f.written {
+			return errors.New("more than one value written by MarshalBencodeTo")
+		}
+		f.written = true
+	}
+	if f.dict {
+		if !f.wantValue {
+			return errors.New("value written in a dictionary where a key was expected")
+		}
+		f.wantValue = false
+	}
+	return nil
+}
+
+// marshalTo calls the MarshalBencodeTo method of m, checking that it writes
+// exactly one complete value.
+func (e *Encoder) marshalTo(m MarshalerTo) error {
+	saved := e.stack
+	e.stack = append(e.stack[len(e.stack):], frame{single: true})
+	defer func() { e.stack = saved }()
+
+	if err := m.MarshalBencodeTo(e); err != nil {
+		return err
+	}
+	switch {
+	case len(e.stack) > 1:
+		return fmt.Errorf("MarshalBencodeTo for type %T left %d lists or dictionaries open", m, len(e.stack)-1)
+	case !e.stack[0].written:
+		return fmt.Errorf("MarshalBencodeTo for type %T wrote no value", m)
+	}
+	return nil
+}
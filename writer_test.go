@@ -0,0 +1,131 @@
+package bencode
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncoderTokens(t *testing.T) {
+	type testCase struct {
+		write func(e *Encoder) error
+		out   string
+		err   bool
+	}
+
+	// run calls each function in order and stops at the first error.
+	run := func(fns ...func() error) error {
+		for _, fn := range fns {
+			if err := fn(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var cases = []testCase{
+		{func(e *Encoder) error {
+			return run(e.BeginList, func() error { return e.WriteInt(1) },
+				func() error { return e.WriteString("foo") }, e.End)
+		}, `li1e3:fooe`, false},
+
+		{func(e *Encoder) error {
+			return run(e.BeginDict,
+				func() error { return e.Key("a") }, func() error { return e.WriteInt(-1) },
+				func() error { return e.Key("b") }, e.BeginList, e.End,
+				func() error { return e.Key("c") }, func() error { return e.WriteValue(map[string]int{"x": 1}) },
+				e.End)
+		}, `d1:ai-1e1:ble1:cd1:xi1eee`, false},
+
+		// Encode writes values inside open containers
+		{func(e *Encoder) error {
+			return run(e.BeginList, func() error { return e.Encode([]string{"a"}) }, e.End)
+		}, `ll1:aee`, false},
+
+		// nested containers close in order
+		{func(e *Encoder) error {
+			return run(e.BeginList, e.BeginList, e.BeginDict, e.End, e.End, e.End)
+		}, `lldeee`, false},
+
+		// keys must be strictly increasing
+		{func(e *Encoder) error {
+			return run(e.BeginDict,
+				func() error { return e.Key("b") }, func() error { return e.WriteInt(1) },
+				func() error { return e.Key("a") })
+		}, ``, true},
+		{func(e *Encoder) error {
+			return run(e.BeginDict,
+				func() error { return e.Key("a") }, func() error { return e.WriteInt(1) },
+				func() error { return e.Key("a") })
+		}, ``, true},
+
+		// values where a key is expected
+		{func(e *Encoder) error {
+			return run(e.BeginDict, func() error { return e.WriteInt(1) })
+		}, ``, true},
+		{func(e *Encoder) error {
+			return run(e.BeginDict, e.BeginList)
+		}, ``, true},
+
+		// keys outside a dictionary, or twice in a row
+		{func(e *Encoder) error { return e.Key("a") }, ``, true},
+		{func(e *Encoder) error {
+			return run(e.BeginList, func() error { return e.Key("a") })
+		}, ``, true},
+		{func(e *Encoder) error {
+			return run(e.BeginDict, func() error { return e.Key("a") }, func() error { return e.Key("b") })
+		}, ``, true},
+
+		// End without a container or before a pending value
+		{func(e *Encoder) error { return e.End() }, ``, true},
+		{func(e *Encoder) error {
+			return run(e.BeginDict, func() error { return e.Key("a") }, e.End)
+		}, ``, true},
+
+		// unclosed containers
+		{func(e *Encoder) error { return run(e.BeginList, e.BeginDict, e.End) }, ``, true},
+
+		// nil values cannot be written
+		{func(e *Encoder) error {
+			return run(e.BeginList, func() error { return e.WriteValue((*int)(nil)) })
+		}, ``, true},
+		{func(e *Encoder) error { return e.WriteValue(nil) }, ``, true},
+		{func(e *Encoder) error {
+			return run(e.BeginDict, func() error { return e.Key("a") },
+				func() error { return e.Encode((*int)(nil)) }, e.End)
+		}, ``, true},
+		{func(e *Encoder) error {
+			return run(e.BeginDict, func() error { return e.Key("a") },
+				func() error { return e.Encode(nil) }, e.End)
+		}, ``, true},
+
+		// Encode leaves nil values out of lists
+		{func(e *Encoder) error {
+			return run(e.BeginList, func() error { return e.Encode((*int)(nil)) }, e.End)
+		}, `le`, false},
+		{func(e *Encoder) error {
+			return run(e.BeginList, func() error { return e.Encode(nil) },
+				func() error { return e.Encode(1) }, e.End)
+		}, `li1ee`, false},
+		{func(e *Encoder) error { return e.Encode(nil) }, ``, false},
+	}
+
+	for i, tt := range cases {
+		buf := new(bytes.Buffer)
+		enc := NewEncoder(buf)
+		err := tt.write(enc)
+		if err == nil {
+			err = enc.Close()
+		}
+		if !tt.err && err != nil {
+			t.Errorf("#%d: Unexpected err: %v", i, err)
+			continue
+		}
+		if tt.err && err == nil {
+			t.Errorf("#%d: Expected err is nil", i)
+			continue
+		}
+		if !tt.err && tt.out != buf.String() {
+			t.Errorf("#%d: Val: %q != %q", i, buf.String(), tt.out)
+		}
+	}
+}